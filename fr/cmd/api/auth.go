@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// apiKeyPrefix - prefix of issued keys (so they are easy to spot, ex.: in leaked logs)
+const apiKeyPrefix = "fr_"
+
+// apiKeyContextKey - context key of the api key that authenticated the request
+type apiKeyContextKey struct{}
+
+// authenticate - middleware that only lets requests with a valid api key through (X-API-Key or Authorization: Bearer)
+func (app *Config) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.Settings.Auth.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := requestAPIKey(r)
+		if key == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.errorJSON(w, errors.New("missing api key"), http.StatusUnauthorized)
+			return
+		}
+
+		apiKey, err := app.findAPIKey(key)
+		if errors.Is(err, data.ErrAPIKeyNotFound) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.errorJSON(w, errors.New("invalid api key"), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			app.failJSON(w, "Failed to load api key from Mongo", &storageError{Err: err}, err.Error())
+			return
+		}
+
+		// the key acts on behalf of its tenant (credentials, quotes, rules and metrics)
+		tenant, err := app.findTenant(apiKey)
+		if errors.Is(err, data.ErrTenantNotFound) {
+			app.errorJSON(w, errors.New("api key's tenant not found"), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			app.failJSON(w, "Failed to load tenant from Mongo", &storageError{Err: err}, err.Error())
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)
+		next.ServeHTTP(w, r.WithContext(withTenant(ctx, tenant)))
+	})
+}
+
+// requireScope - middleware that only lets requests through when their api key has a scope
+func (app *Config) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.Settings.Auth.Enabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			apiKey, _ := r.Context().Value(apiKeyContextKey{}).(data.APIKey)
+			if !apiKey.HasScope(scope) {
+				app.errorJSON(w, fmt.Errorf("api key is missing the '%s' scope", scope), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// findAPIKey - admin key (from settings, with every scope) or a stored key that was not revoked
+func (app *Config) findAPIKey(key string) (data.APIKey, error) {
+	adminKey := app.Settings.Auth.AdminKey
+	if adminKey != "" && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+		return data.APIKey{Name: "admin", Scopes: data.Scopes}, nil
+	}
+
+	return app.Repo.FindAPIKeyByHash(hashAPIKey(key))
+}
+
+// requestAPIKey - key sent by the client (empty when there is none)
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+
+	scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(key)
+	}
+
+	return ""
+}
+
+// generateAPIKey - creates a random key (ex.: fr_3f9c...), returning it and its hash (the only part stored)
+func generateAPIKey() (key, hash string, err error) {
+	random := make([]byte, 32)
+	_, err = rand.Read(random)
+	if err != nil {
+		return "", "", err
+	}
+
+	key = apiKeyPrefix + hex.EncodeToString(random)
+
+	return key, hashAPIKey(key), nil
+}
+
+// hashAPIKey - sha256 of a key (keys are random, so a slow hash is not needed)
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkAPIKey - verifies if a key to be issued has a name and known scopes
+// (keys of a tenant can not have admin scopes, which act on every tenant)
+func checkAPIKey(req requestAPIKeyCreate) (args []string) {
+	args = make([]string, 0)
+
+	if strings.TrimSpace(req.Name) == "" {
+		args = append(args, "Name is required")
+	}
+
+	if len(req.Scopes) == 0 {
+		args = append(args, "Scopes are required")
+	}
+
+	for key, scope := range req.Scopes {
+		if !slices.Contains(data.Scopes, scope) {
+			args = append(args, fmt.Sprintf("Scope is invalid for Scopes[%d] (%s)", key, strings.Join(data.Scopes, ", ")))
+		}
+
+		if req.Shipper != "" && slices.Contains(data.AdminScopes, scope) {
+			args = append(args, fmt.Sprintf("Scope is not allowed for keys of a tenant for Scopes[%d] (%s)", key, scope))
+		}
+	}
+
+	return args
+}
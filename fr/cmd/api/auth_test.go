@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+const testAdminKey = "0123456789abcdef0123456789abcdef"
+
+// newAuthApp - app with authentication enabled and a stored key that can only read quotes (returned along with it)
+func newAuthApp(t *testing.T) (*Config, string) {
+	repo := data.NewMongoTestRepository(nil)
+
+	app := &Config{Repo: repo}
+	app.Settings.Auth = authSettings{Enabled: true, AdminKey: testAdminKey}
+
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() error = %v", err)
+	}
+	_, _ = repo.InsertAPIKey(data.APIKey{Name: "storefront", Hash: hash, Scopes: []string{data.ScopeQuoteRead}})
+
+	return app, key
+}
+
+func TestConfig_authenticate(t *testing.T) {
+	app, key := newAuthApp(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "test #1 - missing key", method: http.MethodGet, path: "/quotes", wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "test #2 - invalid key", method: http.MethodGet, path: "/quotes", headers: map[string]string{"X-API-Key": "fr_unknown"}, wantStatus: http.StatusUnauthorized, wantCode: "unauthorized"},
+		{name: "test #3 - valid key", method: http.MethodGet, path: "/quotes", headers: map[string]string{"X-API-Key": key}, wantStatus: http.StatusOK},
+		{name: "test #4 - bearer key", method: http.MethodGet, path: "/quotes", headers: map[string]string{"Authorization": "Bearer " + key}, wantStatus: http.StatusOK},
+		{name: "test #5 - missing scope", method: http.MethodGet, path: "/metrics", headers: map[string]string{"X-API-Key": key}, wantStatus: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "test #6 - admin key", method: http.MethodGet, path: "/metrics", headers: map[string]string{"X-API-Key": testAdminKey}, wantStatus: http.StatusOK},
+		{name: "test #7 - keys need admin scope", method: http.MethodGet, path: "/keys", headers: map[string]string{"X-API-Key": key}, wantStatus: http.StatusForbidden, wantCode: "forbidden"},
+		{name: "test #8 - open heartbeat", method: http.MethodGet, path: "/ping", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rr := httptest.NewRecorder()
+
+			app.routes().ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d but got %d (%s)", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+
+			if tt.wantCode == "" {
+				return
+			}
+
+			var got jsonResponse
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			if got.Code != tt.wantCode {
+				t.Errorf("expected code %s but got %s", tt.wantCode, got.Code)
+			}
+		})
+	}
+}
+
+func TestConfig_APIKeys(t *testing.T) {
+	app, _ := newAuthApp(t)
+
+	call := func(method, path, key string, body any) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(body)
+
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	// issue
+	rr := call(http.MethodPost, "/keys", testAdminKey, requestAPIKeyCreate{Name: "storefront", Scopes: []string{data.ScopeQuoteCreate, data.ScopeMetricsRead}})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected %d but got %d (%s)", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var issued responseAPIKey
+	_ = json.Unmarshal(rr.Body.Bytes(), &issued)
+
+	if !strings.HasPrefix(issued.Key, apiKeyPrefix) || !strings.HasPrefix(issued.Key, issued.Prefix) {
+		t.Errorf("expected a key starting with %s and its prefix but got %+v", apiKeyPrefix, issued)
+	}
+
+	// issued key works, with its scopes
+	if rr := call(http.MethodGet, "/metrics", issued.Key, nil); rr.Code != http.StatusOK {
+		t.Errorf("expected %d with the issued key but got %d", http.StatusOK, rr.Code)
+	}
+
+	// list does not show keys (nor their hashes)
+	rr = call(http.MethodGet, "/keys", testAdminKey, nil)
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), issued.Key) || strings.Contains(rr.Body.String(), hashAPIKey(issued.Key)) {
+		t.Errorf("expected keys without secrets but got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// revoke
+	if rr := call(http.MethodDelete, "/keys/"+issued.ID.Hex(), testAdminKey, nil); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected %d but got %d", http.StatusNoContent, rr.Code)
+	}
+
+	if rr := call(http.MethodGet, "/metrics", issued.Key, nil); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected %d with the revoked key but got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	if rr := call(http.MethodDelete, "/keys/"+issued.ID.Hex(), testAdminKey, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected %d revoking it again but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// invalid scopes
+	rr = call(http.MethodPost, "/keys", testAdminKey, requestAPIKeyCreate{Name: "storefront", Scopes: []string{"everything"}})
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      requestAPIKeyCreate
+		wantArgs int
+	}{
+		{name: "test #1 - valid key", req: requestAPIKeyCreate{Name: "storefront", Scopes: []string{data.ScopeQuoteCreate}}, wantArgs: 0},
+		{name: "test #2 - missing name and scopes", req: requestAPIKeyCreate{}, wantArgs: 2},
+		{name: "test #3 - unknown scope", req: requestAPIKeyCreate{Name: "storefront", Scopes: []string{data.ScopeQuoteCreate, "quote:delete"}}, wantArgs: 1},
+		{name: "test #4 - admin scope in a tenant's key", req: requestAPIKeyCreate{Name: "storefront", Scopes: []string{data.ScopeQuoteCreate, data.ScopeKeysAdmin}, Shipper: "11222333000181"}, wantArgs: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotArgs := checkAPIKey(tt.req); len(gotArgs) != tt.wantArgs {
+				t.Errorf("checkAPIKey() = %v, want %d arguments", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestRequestAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantKey string
+	}{
+		{name: "test #1 - x-api-key", headers: map[string]string{"X-API-Key": "fr_1"}, wantKey: "fr_1"},
+		{name: "test #2 - bearer", headers: map[string]string{"Authorization": "bearer fr_2"}, wantKey: "fr_2"},
+		{name: "test #3 - other scheme", headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, wantKey: ""},
+		{name: "test #4 - none", wantKey: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if gotKey := requestAPIKey(req); gotKey != tt.wantKey {
+				t.Errorf("requestAPIKey() = %q, want %q", gotKey, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// maxMemoryCacheEntries - entries kept by the in-memory cache (expired ones are dropped first)
+const maxMemoryCacheEntries = 1000
+
+// cacheBackends - valid values of the cache.backend setting
+var cacheBackends = map[string]bool{
+	"none":   true,
+	"memory": true,
+	"mongo":  true,
+}
+
+// QuoteCache - stores providers' responses (encoded) until they expire
+type QuoteCache interface {
+	Get(key string) (body []byte, expiresAt time.Time, ok bool)
+	Set(key string, body []byte, expiresAt time.Time)
+}
+
+// setUpCache - sets up the cache of providers' responses (nil when it is disabled)
+func (app *Config) setUpCache(settings cacheSettings) {
+	switch settings.Backend {
+	case "memory":
+		app.Cache = newMemoryCache(maxMemoryCacheEntries)
+	case "mongo":
+		app.Cache = &mongoCache{Repo: app.Repo}
+	default:
+		app.Cache = nil
+	}
+}
+
+// memoryCache - cache kept in the app's memory (lost on restarts, not shared between instances)
+type memoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]data.CacheEntry
+	maxEntries int
+}
+
+// newMemoryCache - creates an empty in-memory cache
+func newMemoryCache(maxEntries int) *memoryCache {
+	return &memoryCache{entries: make(map[string]data.CacheEntry), maxEntries: maxEntries}
+}
+
+// Get - gets a response that has not expired yet
+func (c *memoryCache) Get(key string) ([]byte, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !entry.ExpiresAt.After(time.Now()) {
+		return nil, time.Time{}, false
+	}
+
+	return entry.Body, entry.ExpiresAt, true
+}
+
+// Set - caches a response, making room for it when the cache is full
+func (c *memoryCache) Set(key string, body []byte, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		// expired entries first, then the one closest to expire
+		now := time.Now()
+		closest := ""
+		for entryKey, entry := range c.entries {
+			if !entry.ExpiresAt.After(now) {
+				delete(c.entries, entryKey)
+				continue
+			}
+
+			if closest == "" || entry.ExpiresAt.Before(c.entries[closest].ExpiresAt) {
+				closest = entryKey
+			}
+		}
+
+		if len(c.entries) >= c.maxEntries {
+			delete(c.entries, closest)
+		}
+	}
+
+	c.entries[key] = data.CacheEntry{Key: key, Body: body, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+}
+
+// mongoCache - cache stored in mongo (shared between instances)
+type mongoCache struct {
+	Repo data.RepositoryPattern
+}
+
+// Get - gets a response that has not expired yet (errors are misses)
+func (c *mongoCache) Get(key string) ([]byte, time.Time, bool) {
+	entry, err := c.Repo.FindCacheEntry(key)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	return entry.Body, entry.ExpiresAt, true
+}
+
+// Set - caches a response (errors are logged by the repository, the response is still used)
+func (c *mongoCache) Set(key string, body []byte, expiresAt time.Time) {
+	_ = c.Repo.SaveCacheEntry(data.CacheEntry{Key: key, Body: body, ExpiresAt: expiresAt})
+}
+
+// cacheKey - hash of a freterapido request, without the shipper's token (which does not change the offers)
+// volumes are sorted, so the same volumes in another order share the key
+func cacheKey(reqAPI requestAPI) string {
+	reqAPI.Shipper.Token = ""
+
+	dispatchers := make([]dispatcher, 0, len(reqAPI.Dispatchers))
+	for _, value := range reqAPI.Dispatchers {
+		volumes := append([]volumeApi(nil), value.Volumes...)
+		sort.SliceStable(volumes, func(i, j int) bool {
+			return fmt.Sprintf("%+v", volumes[i]) < fmt.Sprintf("%+v", volumes[j])
+		})
+
+		value.Volumes = volumes
+		dispatchers = append(dispatchers, value)
+	}
+	reqAPI.Dispatchers = dispatchers
+
+	payload, _ := json.Marshal(reqAPI)
+	hash := sha256.Sum256(payload)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// cacheExpiration - when a response stops being valid: its first offer to expire, limited to ttl from now
+// responses without offers (or without expiration) are not cached
+func cacheExpiration(resAPI responseAPI, now time.Time, ttl time.Duration) (expiresAt time.Time, ok bool) {
+	for _, dispatcher := range resAPI.Dispatchers {
+		for _, value := range dispatcher.Offers {
+			if value.Expiration.IsZero() {
+				continue
+			}
+
+			if !ok || value.Expiration.Before(expiresAt) {
+				expiresAt, ok = value.Expiration, true
+			}
+		}
+	}
+
+	if !ok {
+		return expiresAt, false
+	}
+
+	if limit := now.Add(ttl); expiresAt.After(limit) {
+		expiresAt = limit
+	}
+
+	return expiresAt, expiresAt.After(now)
+}
+
+type cacheStatusKey struct{}
+
+// cacheStatus - Cache-Status (RFC 9211) entries of the providers called by a request
+type cacheStatus struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// withCacheStatus - context where providers record if their responses came from the cache
+func withCacheStatus(ctx context.Context) (context.Context, *cacheStatus) {
+	status := &cacheStatus{}
+	return context.WithValue(ctx, cacheStatusKey{}, status), status
+}
+
+// recordCacheStatus - records a Cache-Status entry (ex.: "freterapido; hit; ttl=60"), when the context has a cacheStatus
+func recordCacheStatus(ctx context.Context, entry string) {
+	status, ok := ctx.Value(cacheStatusKey{}).(*cacheStatus)
+	if !ok {
+		return
+	}
+
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	status.entries = append(status.entries, entry)
+}
+
+// String - value of the Cache-Status header ("" when no provider uses the cache)
+func (status *cacheStatus) String() string {
+	status.mu.Lock()
+	defer status.mu.Unlock()
+
+	sort.Strings(status.entries)
+	return strings.Join(status.entries, ", ")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+	"github.com/mtrdgs/fr/fakefr"
+)
+
+func TestCacheKey(t *testing.T) {
+	p := &freteRapidoProvider{RegisteredNumber: "25438296000158", Token: "token", PlatformCode: "platform", Origins: []origin{{Zipcode: "29161376"}}}
+	key := cacheKey(p.buildRequestAPI(validRequestQuote()))
+
+	tests := []struct {
+		name     string
+		change   func(p *freteRapidoProvider, req *requestQuote)
+		wantSame bool
+	}{
+		{name: "test #1 - same request", change: func(p *freteRapidoProvider, req *requestQuote) {}, wantSame: true},
+		{name: "test #2 - another token", change: func(p *freteRapidoProvider, req *requestQuote) { p.Token = "other" }, wantSame: true},
+		{
+			name: "test #3 - volumes in another order",
+			change: func(p *freteRapidoProvider, req *requestQuote) {
+				req.Volumes[0], req.Volumes[1] = req.Volumes[1], req.Volumes[0]
+			},
+			wantSame: true,
+		},
+		{name: "test #4 - another destination", change: func(p *freteRapidoProvider, req *requestQuote) { req.Recipient.Address.Zipcode = "88010000" }},
+		{name: "test #5 - another shipper", change: func(p *freteRapidoProvider, req *requestQuote) { p.RegisteredNumber = "1" }},
+		{name: "test #6 - another volume", change: func(p *freteRapidoProvider, req *requestQuote) { req.Volumes[0].Amount = 3 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := *p
+			req := validRequestQuote()
+			tt.change(&provider, &req)
+
+			gotKey := cacheKey(provider.buildRequestAPI(req))
+			if (gotKey == key) != tt.wantSame {
+				t.Errorf("cacheKey() same = %v, want %v", gotKey == key, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestCacheExpiration(t *testing.T) {
+	now := time.Date(2025, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	// response with offers expiring in the given durations (0 means without expiration)
+	response := func(durations ...time.Duration) (resAPI responseAPI) {
+		var offers []offer
+		for _, duration := range durations {
+			var expiration time.Time
+			if duration != 0 {
+				expiration = now.Add(duration)
+			}
+			offers = append(offers, offer{Expiration: expiration})
+		}
+
+		resAPI.Dispatchers = []dispatcherAPI{{Offers: offers}}
+		return resAPI
+	}
+
+	tests := []struct {
+		name          string
+		resAPI        responseAPI
+		wantExpiresAt time.Time
+		wantOk        bool
+	}{
+		{name: "test #1 - first offer to expire", resAPI: response(20*time.Minute, 0, 5*time.Minute), wantExpiresAt: now.Add(5 * time.Minute), wantOk: true},
+		{name: "test #2 - limited to ttl", resAPI: response(24 * time.Hour), wantExpiresAt: now.Add(30 * time.Minute), wantOk: true},
+		{name: "test #3 - already expired", resAPI: response(-time.Minute), wantOk: false},
+		{name: "test #4 - without expiration", resAPI: response(0), wantOk: false},
+		{name: "test #5 - without offers", resAPI: responseAPI{}, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotExpiresAt, gotOk := cacheExpiration(tt.resAPI, now, 30*time.Minute)
+
+			if gotOk != tt.wantOk || (gotOk && !gotExpiresAt.Equal(tt.wantExpiresAt)) {
+				t.Errorf("cacheExpiration() = %v, %v, want %v, %v", gotExpiresAt, gotOk, tt.wantExpiresAt, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestQuoteCache(t *testing.T) {
+	caches := map[string]QuoteCache{
+		"memory": newMemoryCache(2),
+		"mongo":  &mongoCache{Repo: data.NewMongoTestRepository(nil)},
+	}
+
+	for name, cache := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache.Set("valid", []byte("a"), time.Now().Add(time.Minute))
+			cache.Set("expired", []byte("b"), time.Now().Add(-time.Minute))
+
+			if body, _, ok := cache.Get("valid"); !ok || string(body) != "a" {
+				t.Errorf("Get(valid) = %s, %v, want a, true", body, ok)
+			}
+
+			if _, _, ok := cache.Get("expired"); ok {
+				t.Errorf("Get(expired) found an expired entry")
+			}
+
+			if _, _, ok := cache.Get("unknown"); ok {
+				t.Errorf("Get(unknown) found an entry")
+			}
+		})
+	}
+
+	// full in-memory cache drops expired entries first, then the one closest to expire
+	cache := newMemoryCache(2)
+	cache.Set("a", []byte("a"), time.Now().Add(time.Hour))
+	cache.Set("b", []byte("b"), time.Now().Add(time.Minute))
+	cache.Set("c", []byte("c"), time.Now().Add(time.Hour))
+
+	if _, _, ok := cache.Get("b"); ok || len(cache.entries) != 2 {
+		t.Errorf("memoryCache kept %d entries (b found: %v), want 2 (without b)", len(cache.entries), ok)
+	}
+}
+
+func TestFreteRapidoProvider_simulate(t *testing.T) {
+	server := fakefr.NewServer(fakefr.Options{})
+	defer server.Close()
+
+	p := newFakeProvider(server)
+	p.Cache = newMemoryCache(maxMemoryCacheEntries)
+	p.CacheTTL = 30 * time.Minute
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus string
+		wantCalls  int
+	}{
+		{name: "test #1 - miss", token: "token", wantStatus: "freterapido; fwd=miss; stored", wantCalls: 1},
+		{name: "test #2 - hit", token: "token", wantStatus: "freterapido; hit; ttl=", wantCalls: 1},
+		{name: "test #3 - hit with another token", token: "other", wantStatus: "freterapido; hit; ttl=", wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p.Token = tt.token
+
+			ctx, status := withCacheStatus(context.Background())
+			carriers, err := p.Quote(ctx, validRequestQuote())
+			if err != nil || len(carriers) == 0 {
+				t.Fatalf("freteRapidoProvider.Quote() = %d offers, error %v", len(carriers), err)
+			}
+
+			if !strings.HasPrefix(status.String(), tt.wantStatus) {
+				t.Errorf("Cache-Status = %q, want %q", status.String(), tt.wantStatus)
+			}
+
+			if calls := len(server.Handler.Requests()); calls != tt.wantCalls {
+				t.Errorf("api calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
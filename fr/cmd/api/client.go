@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// circuit breaker states
+const (
+	breakerDisabled = "disabled"
+	breakerClosed   = "closed"    // requests go through
+	breakerOpen     = "open"      // requests fail right away, until the cooldown is over
+	breakerHalfOpen = "half_open" // a single trial request goes through, to check if the upstream is back
+)
+
+// circuitOpenError - returned (without calling the upstream) while the circuit is open
+type circuitOpenError struct {
+	RetryAfter time.Duration // when a trial request would be allowed
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open, retry after %s", e.RetryAfter)
+}
+
+// newClient - http client used to call providers, with timeouts, retries and a circuit breaker (from settings),
+// charging every attempt to the quota of its shipper credential
+func newClient(s clientSettings, limiter *rateLimiter) (*http.Client, *circuitBreaker) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: s.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = s.ConnectTimeout
+	transport.ResponseHeaderTimeout = s.ResponseTimeout
+
+	breaker := &circuitBreaker{Failures: s.BreakerFailures, Cooldown: s.BreakerCooldown}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			Next:    transport,
+			Retries: s.Retries,
+			Backoff: s.RetryBackoff,
+			Breaker: breaker,
+			Limiter: limiter,
+		},
+	}, breaker
+}
+
+// retryTransport - retries requests that failed before the upstream handled them (or that it could not handle),
+// waiting a jittered exponential backoff between attempts, and stops calling an upstream that keeps failing
+// requests are retried even when they are posts, as quote simulations do not change anything
+type retryTransport struct {
+	Next    http.RoundTripper
+	Retries int           // extra attempts
+	Backoff time.Duration // base wait (doubled on every attempt)
+	Breaker *circuitBreaker
+	Limiter *rateLimiter // quota of calls per shipper credential (of the request's context)
+}
+
+// RoundTrip - sends a request, retrying it when it is worth it
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		trial, err := t.Breaker.allow()
+		if err != nil {
+			return nil, err
+		}
+
+		// wait for the turn of the shipper's credential (or give up, when its queue is full),
+		// only for attempts that will be sent
+		if credential, ok := credentialFromContext(req.Context()); ok {
+			if err := t.Limiter.Wait(req.Context(), credential.Shipper, credential.Token); err != nil {
+				t.Breaker.forget(trial)
+				return nil, err
+			}
+		}
+
+		// every attempt needs a fresh body
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		res, err := t.Next.RoundTrip(attemptReq)
+
+		// a client that gave up says nothing about the upstream (timeouts do)
+		if errors.Is(attemptReq.Context().Err(), context.Canceled) || errors.Is(err, context.Canceled) {
+			t.Breaker.forget(trial)
+		} else {
+			t.Breaker.record(trial, err == nil && res.StatusCode < http.StatusInternalServerError)
+		}
+
+		// done, or not worth another attempt?
+		replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+		if attempt >= t.Retries || !replayable || !retryable(res, err) {
+			return res, err
+		}
+
+		// drop the failed response, so its connection can be reused
+		if res != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, maxUpstreamBody))
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(backoff(t.Backoff, attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryable - checks if a failure is worth another attempt: connection reset (or closed) by the upstream,
+// and upstream (or its gateway) unavailable; timeouts are not retried, as the upstream may be just slow
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// backoff - wait before the next attempt: base doubled on every attempt, half of it random (so clients do not retry together)
+func backoff(base time.Duration, attempt int) time.Duration {
+	wait := base << attempt
+	if wait <= 0 {
+		return 0
+	}
+
+	return wait/2 + rand.N(wait/2+1)
+}
+
+// circuitBreaker - stops calling an upstream after consecutive failures (5xx or no response), for a cooldown,
+// so a failing upstream does not hold every handler until it times out
+type circuitBreaker struct {
+	Failures int           // consecutive failures that open the circuit (0 to disable)
+	Cooldown time.Duration // time the circuit stays open before a trial request
+
+	mu       sync.Mutex
+	state    string
+	failures int // consecutive
+	openedAt time.Time
+	trial    bool             // a trial request is in flight (half open)
+	now      func() time.Time // overridden by tests
+}
+
+// breakerStatus - state of a circuit breaker (shown in /status)
+type breakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailureThreshold    int        `json:"failure_threshold,omitempty"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"` // when a trial request is allowed
+}
+
+// allow - checks if a request can be sent (while open, only a single trial after the cooldown),
+// telling if it is the trial (only its result changes a circuit that is not closed)
+func (b *circuitBreaker) allow() (trial bool, err error) {
+	if b == nil || b.Failures <= 0 {
+		return false, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	retryAfter := b.openedAt.Add(b.Cooldown).Sub(b.clock())
+
+	switch b.currentState() {
+	case breakerOpen:
+		if retryAfter > 0 {
+			return false, &circuitOpenError{RetryAfter: retryAfter}
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+		return true, nil
+	case breakerHalfOpen:
+		if b.trial {
+			return false, &circuitOpenError{RetryAfter: max(retryAfter, 0)}
+		}
+		b.trial = true
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// forget - drops a request without result (ex.: cancelled by the client); a dropped trial lets another one go
+func (b *circuitBreaker) forget(trial bool) {
+	if b == nil || b.Failures <= 0 || !trial {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// record - counts the result of a request (a success closes the circuit, failures open it)
+// while the circuit is not closed, only the trial counts (requests sent before it opened are too late)
+func (b *circuitBreaker) record(trial bool, success bool) {
+	if b == nil || b.Failures <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trial = false
+	} else if b.currentState() != breakerClosed {
+		return
+	}
+
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.currentState() == breakerHalfOpen || b.failures >= b.Failures {
+		b.state = breakerOpen
+		b.openedAt = b.clock()
+	}
+}
+
+// status - current state of the circuit breaker
+func (b *circuitBreaker) status() breakerStatus {
+	if b == nil || b.Failures <= 0 {
+		return breakerStatus{State: breakerDisabled}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := breakerStatus{State: b.currentState(), ConsecutiveFailures: b.failures, FailureThreshold: b.Failures}
+	if status.State != breakerClosed {
+		openedAt, retryAt := b.openedAt, b.openedAt.Add(b.Cooldown)
+		status.OpenedAt, status.RetryAt = &openedAt, &retryAt
+	}
+
+	return status
+}
+
+// currentState - state of the circuit (closed until it fails)
+func (b *circuitBreaker) currentState() string {
+	if b.state == "" {
+		return breakerClosed
+	}
+
+	return b.state
+}
+
+// clock - current time
+func (b *circuitBreaker) clock() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+
+	return time.Now()
+}
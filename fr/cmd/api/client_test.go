@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// flakyServer - upstream that answers the listed statuses in order (and 200 after them), keeping the bodies it got
+type flakyServer struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.bodies = append(s.bodies, string(body))
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	s.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		retries      int
+		wantStatus   int
+		wantAttempts int
+	}{
+		{name: "test #1 - no failure", statuses: nil, retries: 2, wantStatus: http.StatusOK, wantAttempts: 1},
+		{name: "test #2 - retried until it works", statuses: []int{http.StatusServiceUnavailable, http.StatusBadGateway}, retries: 2, wantStatus: http.StatusOK, wantAttempts: 3},
+		{name: "test #3 - retries exhausted", statuses: []int{http.StatusGatewayTimeout, http.StatusGatewayTimeout, http.StatusGatewayTimeout}, retries: 2, wantStatus: http.StatusGatewayTimeout, wantAttempts: 3},
+		{name: "test #4 - not retryable", statuses: []int{http.StatusBadRequest}, retries: 2, wantStatus: http.StatusBadRequest, wantAttempts: 1},
+		{name: "test #5 - server error not retryable", statuses: []int{http.StatusInternalServerError}, retries: 2, wantStatus: http.StatusInternalServerError, wantAttempts: 1},
+		{name: "test #6 - retries disabled", statuses: []int{http.StatusServiceUnavailable}, retries: 0, wantStatus: http.StatusServiceUnavailable, wantAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &flakyServer{statuses: tt.statuses}
+			server := httptest.NewServer(upstream)
+			defer server.Close()
+
+			client := &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Retries: tt.retries, Backoff: time.Millisecond}}
+
+			res, err := client.Post(server.URL, "application/json", bytes.NewReader([]byte(`{"quote":1}`)))
+			if err != nil {
+				t.Fatalf("retryTransport.RoundTrip() error = %v", err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.wantStatus {
+				t.Errorf("retryTransport.RoundTrip() status = %d, want %d", res.StatusCode, tt.wantStatus)
+			}
+
+			if len(upstream.bodies) != tt.wantAttempts {
+				t.Errorf("retryTransport.RoundTrip() attempts = %d, want %d", len(upstream.bodies), tt.wantAttempts)
+			}
+
+			// every attempt sends the whole body
+			for key, body := range upstream.bodies {
+				if body != `{"quote":1}` {
+					t.Errorf("retryTransport.RoundTrip() body[%d] = %q", key, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryTransport_connectionReset(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			// close the connection without answering
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Retries: 1, Backoff: time.Millisecond}}
+
+	res, err := client.Post(server.URL, "application/json", bytes.NewReader([]byte(`{}`)))
+	if err != nil {
+		t.Fatalf("retryTransport.RoundTrip() error = %v", err)
+	}
+	res.Body.Close()
+
+	if attempts != 2 {
+		t.Errorf("retryTransport.RoundTrip() attempts = %d, want 2", attempts)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	breaker := &circuitBreaker{Failures: 2, Cooldown: time.Minute, now: func() time.Time { return now }}
+
+	steps := []struct {
+		name      string
+		after     time.Duration // time passed before the step
+		result    *bool         // result recorded when the request is allowed (nil: none)
+		wantAllow bool
+		wantState string
+	}{
+		{name: "test #1 - first failure", result: ptr(false), wantAllow: true, wantState: breakerClosed},
+		{name: "test #2 - threshold reached", result: ptr(false), wantAllow: true, wantState: breakerOpen},
+		{name: "test #3 - open", after: 30 * time.Second, wantAllow: false, wantState: breakerOpen},
+		{name: "test #4 - trial after cooldown", after: 30 * time.Second, wantAllow: true, wantState: breakerHalfOpen},
+		{name: "test #5 - single trial", wantAllow: false, wantState: breakerHalfOpen},
+		{name: "test #6 - trial failed", result: ptr(false), wantState: breakerOpen},
+		{name: "test #7 - another trial", after: time.Minute, result: ptr(true), wantAllow: true, wantState: breakerClosed},
+		{name: "test #8 - closed", wantAllow: true, wantState: breakerClosed},
+	}
+	// last request allowed (trial or not)
+	var trial bool
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(step.after)
+
+			// a result of a request already allowed (trial in flight)
+			if step.result != nil && !step.wantAllow {
+				breaker.record(trial, *step.result)
+			} else {
+				allowedTrial, err := breaker.allow()
+				if (err == nil) != step.wantAllow {
+					t.Fatalf("circuitBreaker.allow() error = %v, want allowed %v", err, step.wantAllow)
+				}
+
+				if err == nil {
+					trial = allowedTrial
+				}
+
+				if err == nil && step.result != nil {
+					breaker.record(trial, *step.result)
+				}
+			}
+
+			if got := breaker.status().State; got != step.wantState {
+				t.Errorf("circuitBreaker.status() state = %s, want %s", got, step.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_inFlight(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	breaker := &circuitBreaker{Failures: 1, Cooldown: time.Minute, now: func() time.Time { return now }}
+
+	// a slow request sent while closed, then a failure that opens the circuit
+	slowTrial, _ := breaker.allow()
+	failedTrial, _ := breaker.allow()
+	breaker.record(failedTrial, false)
+
+	// trial after the cooldown, while the slow request is still in flight
+	now = now.Add(time.Minute)
+	trial, err := breaker.allow()
+	if err != nil || !trial {
+		t.Fatalf("circuitBreaker.allow() = %v %v, want the trial", trial, err)
+	}
+
+	// the slow request neither closes nor reopens the circuit, and does not let another trial go
+	for _, success := range []bool{true, false} {
+		breaker.record(slowTrial, success)
+
+		if got := breaker.status().State; got != breakerHalfOpen {
+			t.Errorf("circuitBreaker.status() state = %s after a late result, want %s", got, breakerHalfOpen)
+		}
+
+		if _, err := breaker.allow(); err == nil {
+			t.Errorf("circuitBreaker.allow() allowed a second trial")
+		}
+	}
+
+	// the trial does
+	breaker.record(trial, true)
+	if got := breaker.status().State; got != breakerClosed {
+		t.Errorf("circuitBreaker.status() state = %s after the trial, want %s", got, breakerClosed)
+	}
+}
+
+func TestRetryTransport_breaker(t *testing.T) {
+	upstream := &flakyServer{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+
+	breaker := &circuitBreaker{Failures: 2, Cooldown: time.Minute}
+	client := &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Retries: 5, Backoff: time.Millisecond, Breaker: breaker}}
+
+	// retries stop as soon as the circuit opens
+	_, err := client.Get(server.URL)
+	var circuitOpen *circuitOpenError
+	if !errors.As(err, &circuitOpen) || circuitOpen.RetryAfter <= 0 || circuitOpen.RetryAfter > time.Minute {
+		t.Errorf("retryTransport.RoundTrip() error = %v, want circuit open until the cooldown is over", err)
+	}
+
+	if len(upstream.bodies) != 2 {
+		t.Errorf("retryTransport.RoundTrip() attempts = %d, want 2", len(upstream.bodies))
+	}
+
+	// failed with a code of its own, even as a provider's failure
+	if status, code := failureStatus(newUpstreamError("freterapido", 0, nil, err)); status != http.StatusServiceUnavailable || code != codeCircuitOpen {
+		t.Errorf("failureStatus() = %d %s, want %d %s", status, code, http.StatusServiceUnavailable, codeCircuitOpen)
+	}
+}
+
+func TestRetryTransport_cancelled(t *testing.T) {
+	// upstream that answers only after the client gave up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	breaker := &circuitBreaker{Failures: 1, Cooldown: time.Minute}
+	client := &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Breaker: breaker}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("retryTransport.RoundTrip() error = %v, want %v", err, context.Canceled)
+	}
+
+	// not an upstream failure
+	if status := breaker.status(); status.State != breakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("circuitBreaker.status() = %+v, want closed without failures", status)
+	}
+
+	// a cancelled trial lets another one go
+	breaker.state, breaker.trial = breakerHalfOpen, true
+	breaker.forget(true)
+	if _, err := breaker.allow(); err != nil {
+		t.Errorf("circuitBreaker.allow() error = %v, want allowed", err)
+	}
+}
+
+func TestConfig_Quote_circuitOpen(t *testing.T) {
+	app := Config{
+		Repo:      data.NewMongoTestRepository(nil),
+		Providers: []QuoteProvider{&stubProvider{name: "freterapido", err: newUpstreamError("freterapido", 0, nil, &circuitOpenError{RetryAfter: 20 * time.Second})}},
+	}
+
+	body, _ := json.Marshal(validRequestQuote())
+
+	req, _ := http.NewRequest(http.MethodPost, "/quote", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(app.Quote)
+
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d but got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	if got := rr.Header().Get("Retry-After"); got != "20" {
+		t.Errorf("expected Retry-After 20 but got %q", got)
+	}
+
+	var got jsonResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+	if got.Code != codeCircuitOpen {
+		t.Errorf("expected code %s but got %s", codeCircuitOpen, got.Code)
+	}
+}
+
+func TestConfig_Status(t *testing.T) {
+	tests := []struct {
+		name      string
+		breaker   *circuitBreaker
+		wantState string
+	}{
+		{name: "test #1 - no breaker", breaker: nil, wantState: breakerDisabled},
+		{name: "test #2 - closed", breaker: &circuitBreaker{Failures: 5, Cooldown: time.Minute}, wantState: breakerClosed},
+		{name: "test #3 - open", breaker: &circuitBreaker{Failures: 5, Cooldown: time.Minute, state: breakerOpen, failures: 5, openedAt: time.Now()}, wantState: breakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := Config{Breaker: tt.breaker}
+
+			req, _ := http.NewRequest(http.MethodGet, "/status", nil)
+			rr := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(app.Status)
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected http.StatusOK but got %d", rr.Code)
+			}
+
+			var got responseStatus
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			if got.Upstream.State != tt.wantState {
+				t.Errorf("expected state %s but got %s", tt.wantState, got.Upstream.State)
+			}
+
+			if (got.Upstream.RetryAt != nil) != (tt.wantState == breakerOpen) {
+				t.Errorf("expected retry_at only when open but got %v", got.Upstream.RetryAt)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 4; attempt++ {
+		wait := 100 * time.Millisecond << attempt
+
+		got := backoff(100*time.Millisecond, attempt)
+		if got < wait/2 || got > wait {
+			t.Errorf("backoff() attempt %d = %v, want between %v and %v", attempt, got, wait/2, wait)
+		}
+	}
+}
+
+// ptr - pointer to a value
+func ptr[T any](value T) *T {
+	return &value
+}
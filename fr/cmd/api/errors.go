@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// error codes - machine-readable reasons of failures (sent as jsonResponse.Code)
+const (
+	codeMissingArguments   = "missing_arguments"
+	codeUpstreamValidation = "upstream_validation_error" // provider rejected the request (ex.: invalid zipcode)
+	codeUpstreamAuth       = "upstream_auth_error"       // provider rejected our credentials
+	codeUpstreamTimeout    = "upstream_timeout"          // provider took too long to answer
+	codeUpstreamError      = "upstream_error"            // provider failed (5xx, unreachable or invalid response)
+	codeStorageError       = "storage_error"             // mongo failed
+	codeRateLimited        = "rate_limited"              // shipper's quota of calls to providers used up
+	codeCircuitOpen        = "circuit_open"              // providers failing, calls to them cut for a while
+)
+
+// failureStatuses - http status sent to the client for each failure
+var failureStatuses = map[string]int{
+	codeUpstreamValidation: http.StatusUnprocessableEntity,
+	codeUpstreamAuth:       http.StatusBadGateway,
+	codeUpstreamTimeout:    http.StatusGatewayTimeout,
+	codeUpstreamError:      http.StatusBadGateway,
+	codeStorageError:       http.StatusServiceUnavailable,
+	codeRateLimited:        http.StatusTooManyRequests,
+	codeCircuitOpen:        http.StatusServiceUnavailable,
+}
+
+// maxUpstreamBody - max size of a provider's error body kept (bigger ones are cut)
+const maxUpstreamBody = 64 << 10 // 64Kb
+
+// upstreamError - failure of a quote provider, with the body it answered (if any)
+type upstreamError struct {
+	Provider   string
+	Code       string // codeUpstream*
+	StatusCode int    // status answered by the provider (0 when it did not answer)
+	Body       []byte // body answered by the provider (ex.: freterapido's validation errors)
+	Err        error  // cause, when the provider did not answer (or its answer is invalid)
+}
+
+// newUpstreamError - classifies a provider's failure by the status it answered (or by the cause, when it did not answer)
+func newUpstreamError(provider string, statusCode int, body []byte, err error) *upstreamError {
+	code := codeUpstreamError
+
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = codeUpstreamValidation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = codeUpstreamAuth
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		code = codeUpstreamTimeout
+	case 0:
+		if isTimeout(err) {
+			code = codeUpstreamTimeout
+		}
+	}
+
+	return &upstreamError{Provider: provider, Code: code, StatusCode: statusCode, Body: body, Err: err}
+}
+
+func (e *upstreamError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s returned status %d", e.Provider, e.StatusCode)
+	}
+
+	return fmt.Sprintf("%s failed: %v", e.Provider, e.Err)
+}
+
+func (e *upstreamError) Unwrap() error {
+	return e.Err
+}
+
+// body - provider's body as json (kept as a string when it is not json)
+func (e *upstreamError) body() json.RawMessage {
+	if len(e.Body) == 0 {
+		return nil
+	}
+
+	if json.Valid(e.Body) {
+		return e.Body
+	}
+
+	body, _ := json.Marshal(string(e.Body))
+	return body
+}
+
+// storageError - failure of the database
+type storageError struct {
+	Err error
+}
+
+func (e *storageError) Error() string {
+	return fmt.Sprintf("storage failed: %v", e.Err)
+}
+
+func (e *storageError) Unwrap() error {
+	return e.Err
+}
+
+// providerFailure - why a provider failed, as sent to the client
+type providerFailure struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body,omitempty"` // provider's own error body
+}
+
+// describeFailure - details a provider's failure for the client
+func describeFailure(err error) providerFailure {
+	_, code := failureStatus(err)
+	failure := providerFailure{Code: code, Message: err.Error()}
+
+	var upstream *upstreamError
+	if errors.As(err, &upstream) {
+		failure.Body = upstream.body()
+	}
+
+	return failure
+}
+
+// describeFailures - details the failure of each provider for the client
+func describeFailures(errs map[string]error) map[string]providerFailure {
+	failures := make(map[string]providerFailure, len(errs))
+	for name, err := range errs {
+		failures[name] = describeFailure(err)
+	}
+
+	return failures
+}
+
+// failureStatus - maps a failure (of providers or storage) to the http status and code sent to the client
+// untyped failures come from providers, so they are upstream errors (or timeouts)
+func failureStatus(err error) (status int, code string) {
+	var upstream *upstreamError
+	var storage *storageError
+	var throttled *throttledError
+	var circuitOpen *circuitOpenError
+
+	switch {
+	case errors.As(err, &storage):
+		code = codeStorageError
+	case errors.As(err, &throttled):
+		code = codeRateLimited
+	case errors.As(err, &circuitOpen):
+		code = codeCircuitOpen
+	case errors.As(err, &upstream):
+		code = upstream.Code
+	case isTimeout(err):
+		code = codeUpstreamTimeout
+	default:
+		code = codeUpstreamError
+	}
+
+	return failureStatuses[code], code
+}
+
+// retryAfter - when a failure that did not call the upstream (rate limited or circuit open) is worth another try
+func retryAfter(err error) (time.Duration, bool) {
+	var throttled *throttledError
+	if errors.As(err, &throttled) {
+		return throttled.RetryAfter, true
+	}
+
+	var circuitOpen *circuitOpenError
+	if errors.As(err, &circuitOpen) {
+		return circuitOpen.RetryAfter, true
+	}
+
+	return 0, false
+}
+
+// isTimeout - checks if a failure happened because something took too long
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
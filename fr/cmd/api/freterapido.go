@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+func init() {
+	registerProvider("freterapido", newFreteRapidoProvider)
+}
+
+// freteRapidoProvider - quote provider backed by freterapido api (simulate module)
+type freteRapidoProvider struct {
+	Client           *http.Client
+	URL              string
+	RegisteredNumber string
+	Token            string
+	PlatformCode     string
+	Origins          []origin // configured warehouses
+
+	Cache    QuoteCache    // nil when responses are not cached
+	CacheTTL time.Duration // max time a response is cached
+}
+
+// newFreteRapidoProvider - creates a freterapido provider with the shipper's info (from settings)
+func newFreteRapidoProvider(app *Config) (QuoteProvider, error) {
+	settings := app.Settings.FreteRapido
+
+	var origins []origin
+	for _, value := range settings.origins() {
+		if _, err := strconv.Atoi(value.Zipcode); err != nil {
+			return nil, fmt.Errorf("invalid origin zipcode %q: %w", value.Zipcode, err)
+		}
+
+		origins = append(origins, origin{Zipcode: value.Zipcode, RegisteredNumber: value.RegisteredNumber})
+	}
+
+	return &freteRapidoProvider{
+		Client:           app.Client,
+		URL:              settings.URL,
+		RegisteredNumber: settings.RegisteredNumber,
+		Token:            settings.Token,
+		PlatformCode:     settings.PlatformCode,
+		Origins:          origins,
+		Cache:            app.Cache,
+		CacheTTL:         app.Settings.Cache.TTL,
+	}, nil
+}
+
+// Name - identifies the provider
+func (p *freteRapidoProvider) Name() string {
+	return "freterapido"
+}
+
+// Quote - builds the request, calls freterapido api and normalizes its offers
+func (p *freteRapidoProvider) Quote(ctx context.Context, reqQuote requestQuote) ([]data.Carrier, error) {
+	// credentials and warehouses of the caller's tenant (settings' ones, when it was not resolved)
+	if tenant, ok := tenantFromContext(ctx); ok {
+		p = p.forTenant(tenant)
+	}
+
+	// build request (needed for external api)
+	requestAPI := p.buildRequestAPI(reqQuote)
+
+	// call freterapido api (simulate module), unless the response is cached
+	responseAPI, err := p.simulate(ctx, requestAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	// format response from api
+	return p.formatResponseAPI(responseAPI).Carrier, nil
+}
+
+// forTenant - copy of the provider using a tenant's credentials and warehouses
+func (p *freteRapidoProvider) forTenant(tenant data.Tenant) *freteRapidoProvider {
+	provider := *p
+	provider.RegisteredNumber = tenant.RegisteredNumber
+	provider.Token = tenant.Token
+	provider.PlatformCode = tenant.PlatformCode
+
+	provider.Origins = nil
+	for _, value := range tenant.Origins {
+		provider.Origins = append(provider.Origins, origin{Zipcode: value.Zipcode, RegisteredNumber: value.RegisteredNumber})
+	}
+
+	return &provider
+}
+
+// buildRequestAPI - creates request to be used at freterapido api from user's input
+func (p *freteRapidoProvider) buildRequestAPI(reqQuote requestQuote) (reqAPI requestAPI) {
+	// shipper
+	reqAPI.Shipper.RegisteredNumber = p.RegisteredNumber
+	reqAPI.Shipper.Token = p.Token
+	reqAPI.Shipper.PlatformCode = p.PlatformCode
+
+	// recipient
+	reqAPI.Recipient.Type = 0        // fixed
+	reqAPI.Recipient.Country = "BRA" // fixed
+	reqAPI.Recipient.Zipcode, _ = strconv.Atoi(reqQuote.Recipient.Address.Zipcode)
+
+	// volumes
+	var volumes []volumeApi
+	for _, value := range reqQuote.Volumes {
+		var volume volumeApi
+		volume.Amount = value.Amount
+		volume.Category = strconv.Itoa(value.Category)
+		volume.Height = value.Height
+		volume.Length = value.Length
+		volume.Price = value.Price
+		volume.Sku = value.Sku
+		volume.UnitaryPrice = value.Price / value.Amount
+		volume.UnitaryWeight = value.UnitaryWeight
+		volume.Width = value.Width
+
+		volumes = append(volumes, volume)
+	}
+
+	// dispatchers (one per origin, every one of them with all volumes)
+	origins := reqQuote.Origins
+	if len(origins) == 0 {
+		origins = p.Origins
+	}
+
+	for _, origin := range origins {
+		var dispatcher dispatcher
+		dispatcher.RegisteredNumber = origin.RegisteredNumber
+		if dispatcher.RegisteredNumber == "" {
+			dispatcher.RegisteredNumber = p.RegisteredNumber
+		}
+		dispatcher.Zipcode, _ = strconv.Atoi(origin.Zipcode)
+		dispatcher.Volumes = volumes
+
+		reqAPI.Dispatchers = append(reqAPI.Dispatchers, dispatcher)
+	}
+
+	// simulation type
+	reqAPI.SimulationType = append(reqAPI.SimulationType, 0) // fixed
+
+	// returns
+	reqAPI.Returns.Composition = false
+	reqAPI.Returns.Volumes = false
+	reqAPI.Returns.AppliedRules = false
+
+	return reqAPI
+}
+
+// simulate - gets the response from the cache or, when it is not there, from freterapido api (caching it until its offers expire)
+// the result is recorded as a Cache-Status entry
+func (p *freteRapidoProvider) simulate(ctx context.Context, reqAPI requestAPI) (resAPI responseAPI, err error) {
+	if p.Cache == nil {
+		return p.postSimulateAPI(ctx, reqAPI)
+	}
+
+	key := cacheKey(reqAPI)
+
+	// cached?
+	if body, expiresAt, ok := p.Cache.Get(key); ok {
+		if err = json.Unmarshal(body, &resAPI); err == nil {
+			recordCacheStatus(ctx, fmt.Sprintf("%s; hit; ttl=%d", p.Name(), int(time.Until(expiresAt).Seconds())))
+			return resAPI, nil
+		}
+	}
+
+	resAPI, err = p.postSimulateAPI(ctx, reqAPI)
+	if err != nil {
+		recordCacheStatus(ctx, p.Name()+"; fwd=miss")
+		return resAPI, err
+	}
+
+	// cache it while its offers are valid
+	expiresAt, ok := cacheExpiration(resAPI, time.Now(), p.CacheTTL)
+	if !ok {
+		recordCacheStatus(ctx, p.Name()+"; fwd=miss")
+		return resAPI, nil
+	}
+
+	body, err := json.Marshal(resAPI)
+	if err != nil {
+		recordCacheStatus(ctx, p.Name()+"; fwd=miss")
+		return resAPI, nil
+	}
+
+	p.Cache.Set(key, body, expiresAt)
+	recordCacheStatus(ctx, p.Name()+"; fwd=miss; stored")
+
+	return resAPI, nil
+}
+
+// postSimulateAPI - calls freterapido api
+func (p *freteRapidoProvider) postSimulateAPI(ctx context.Context, reqAPI requestAPI) (resAPI responseAPI, err error) {
+	// every attempt (retries too) is charged to the shipper's credential
+	ctx = withCredential(ctx, reqAPI.Shipper.RegisteredNumber, reqAPI.Shipper.Token)
+
+	// build request
+	payload, err := json.Marshal(reqAPI)
+	if err != nil {
+		return resAPI, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(payload))
+	if err != nil {
+		return resAPI, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	// send request
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return resAPI, newUpstreamError(p.Name(), 0, nil, err)
+	}
+	defer res.Body.Close()
+
+	// keep the error body (ex.: which field is invalid)
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, maxUpstreamBody))
+		return resAPI, newUpstreamError(p.Name(), res.StatusCode, body, nil)
+	}
+
+	// decode response
+	err = json.NewDecoder(res.Body).Decode(&resAPI)
+	if err != nil {
+		return resAPI, newUpstreamError(p.Name(), 0, nil, fmt.Errorf("failed to decode response: %w", err))
+	}
+
+	return resAPI, nil
+}
+
+// formatResponseAPI - returns a json to be used in mongo insert operation, using info from freterapido api response
+func (p *freteRapidoProvider) formatResponseAPI(entry responseAPI) (result data.QuoteEntry) {
+	// has dispatchers?
+	if len(entry.Dispatchers) == 0 {
+		return result
+	}
+
+	// format response from api (offers from every dispatcher, tagged with its origin)
+	for _, dispatcher := range entry.Dispatchers {
+		for _, value := range dispatcher.Offers {
+			carrier := data.Carrier{
+				Name:     value.Carrier.Name,
+				Service:  value.Modal,
+				Deadline: value.CarrierOriginalDeliveryTime.Days,
+				Price:    value.FinalPrice,
+				Origin:   formatZipcode(dispatcher.ZipcodeOrigin),
+
+				RegisteredNumber: value.Carrier.RegisteredNumber,
+				CompanyName:      value.Carrier.CompanyName,
+				Logo:             value.Carrier.Logo,
+				TableReference:   value.TableReference,
+				HomeDelivery:     value.HomeDelivery,
+				EstimatedDate:    value.DeliveryTime.EstimatedDate,
+			}
+
+			if value.Weights != (weights{}) {
+				carrier.Weights = &data.Weights{Real: float64(value.Weights.Real), Cubed: value.Weights.Cubed, Used: value.Weights.Used}
+			}
+
+			// zero cost means it was not told (the carrier never works for free)
+			if value.CostPrice > 0 {
+				costPrice := value.CostPrice
+				carrier.CostPrice = &costPrice
+				carrier.SetMargin()
+			}
+
+			if !value.Expiration.IsZero() {
+				expiration := value.Expiration
+				carrier.Expiration = &expiration
+			}
+
+			result.Carrier = append(result.Carrier, carrier)
+		}
+	}
+
+	return result
+}
+
+// formatZipcode - formats a numeric zipcode with its 8 digits (leading zeros are lost as int)
+func formatZipcode(zipcode int) string {
+	if zipcode == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%08d", zipcode)
+}
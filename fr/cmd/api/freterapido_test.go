@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+	"github.com/mtrdgs/fr/fakefr"
+)
+
+// newFakeProvider - freterapido provider pointing to a fake simulate api
+func newFakeProvider(server *fakefr.Server) *freteRapidoProvider {
+	return &freteRapidoProvider{
+		Client:           &http.Client{},
+		URL:              server.SimulateURL(),
+		RegisteredNumber: "25438296000158",
+		Token:            "token",
+		PlatformCode:     "platform",
+		Origins:          []origin{{Zipcode: "29161376"}},
+	}
+}
+
+// validRequestQuote - user's request accepted by the fake api
+func validRequestQuote() requestQuote {
+	return requestQuote{
+		Recipient: recipientQuote{Address: address{Zipcode: "01311000"}},
+		Volumes: []volume{
+			{Category: 7, Amount: 1, UnitaryWeight: 5, Price: 349, Sku: "abc-teste-123", Height: 0.2, Width: 0.2, Length: 0.2},
+			{Category: 7, Amount: 2, UnitaryWeight: 4, Price: 556, Sku: "abc-teste-527", Height: 0.4, Width: 0.6, Length: 0.15},
+		},
+	}
+}
+
+func TestFreteRapidoProvider_buildRequestAPI(t *testing.T) {
+	type args struct {
+		reqQuote requestQuote
+		origins  []origin
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantReqAPI requestAPI
+	}{
+		{
+			name: "test #1 - valid request",
+			args: args{
+				reqQuote: requestQuote{
+					Recipient: recipientQuote{
+						Address: address{
+							Zipcode: "12345",
+						},
+					},
+					Volumes: []volume{
+						{
+							Category: 1,
+							Amount:   1,
+							Price:    100.0,
+							Sku:      "SKU123",
+							Height:   10.0,
+							Width:    5.0,
+							Length:   20.0,
+						},
+					},
+				},
+				origins: []origin{{Zipcode: "12345"}},
+			},
+			wantReqAPI: requestAPI{
+				Recipient: recipientApi{
+					Type:    0,
+					Country: "BRA",
+					Zipcode: 12345,
+				},
+				Dispatchers: []dispatcher{
+					{
+						RegisteredNumber: "",
+						Zipcode:          12345,
+						Volumes: []volumeApi{
+							{
+								Category:      "1",
+								Amount:        1,
+								UnitaryWeight: 0,
+								Price:         100.0,
+								UnitaryPrice:  100,
+								Sku:           "SKU123",
+								Height:        10.0,
+								Width:         5.0,
+								Length:        20.0,
+							},
+						},
+					},
+				},
+				SimulationType: []int{0},
+				Returns: returns{
+					Composition:  false,
+					Volumes:      false,
+					AppliedRules: false,
+				},
+			},
+		},
+		{
+			name: "test #2 - origins from request take precedence",
+			args: args{
+				reqQuote: requestQuote{
+					Recipient: recipientQuote{Address: address{Zipcode: "12345"}},
+					Volumes:   []volume{{Category: 1, Amount: 2, Price: 100, Sku: "SKU123", Height: 1, Width: 1, Length: 1}},
+					Origins:   []origin{{Zipcode: "01311000"}, {Zipcode: "29161376", RegisteredNumber: "1"}},
+				},
+				origins: []origin{{Zipcode: "12345"}},
+			},
+			wantReqAPI: requestAPI{
+				Recipient: recipientApi{Type: 0, Country: "BRA", Zipcode: 12345},
+				Dispatchers: []dispatcher{
+					{
+						RegisteredNumber: "",
+						Zipcode:          1311000,
+						Volumes:          []volumeApi{{Category: "1", Amount: 2, Price: 100, UnitaryPrice: 50, Sku: "SKU123", Height: 1, Width: 1, Length: 1}},
+					},
+					{
+						RegisteredNumber: "1",
+						Zipcode:          29161376,
+						Volumes:          []volumeApi{{Category: "1", Amount: 2, Price: 100, UnitaryPrice: 50, Sku: "SKU123", Height: 1, Width: 1, Length: 1}},
+					},
+				},
+				SimulationType: []int{0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &freteRapidoProvider{Origins: tt.args.origins}
+
+			gotReqAPI := p.buildRequestAPI(tt.args.reqQuote)
+
+			if !reflect.DeepEqual(gotReqAPI, tt.wantReqAPI) {
+				t.Errorf("freteRapidoProvider.buildRequestAPI() = %v, want %v", gotReqAPI, tt.wantReqAPI)
+			}
+		})
+	}
+}
+
+func TestFreteRapidoProvider_formatResponseAPI(t *testing.T) {
+	expiration := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	money := func(value data.Money) *data.Money {
+		return &value
+	}
+
+	type args struct {
+		entry responseAPI
+	}
+	tests := []struct {
+		name       string
+		args       args
+		wantResult data.QuoteEntry
+	}{
+		{
+			name: "test #1 - valid json",
+			args: args{
+				entry: responseAPI{
+					Dispatchers: []dispatcherAPI{
+						{
+							ID: "test",
+							Offers: []offer{
+								{
+									Modal:      "test",
+									FinalPrice: 150,
+									Carrier: carrier{
+										Name: "test",
+									},
+									CarrierOriginalDeliveryTime: carrierOriginalDeliveryTime{
+										Days: 1,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantResult: data.QuoteEntry{
+				Carrier: []data.Carrier{
+					{
+						Name:     "test",
+						Service:  "test",
+						Deadline: 1,
+						Price:    150,
+					},
+				},
+			},
+		},
+		{
+			name: "test #2 - offers from every dispatcher",
+			args: args{
+				entry: responseAPI{
+					Dispatchers: []dispatcherAPI{
+						{
+							ZipcodeOrigin: 1311000,
+							Offers:        []offer{{Modal: "a", FinalPrice: 1, Carrier: carrier{Name: "a"}}},
+						},
+						{
+							ZipcodeOrigin: 29161376,
+							Offers:        []offer{{Modal: "b", FinalPrice: 2, Carrier: carrier{Name: "b"}}},
+						},
+					},
+				},
+			},
+			wantResult: data.QuoteEntry{
+				Carrier: []data.Carrier{
+					{Name: "a", Service: "a", Price: 1, Origin: "01311000"},
+					{Name: "b", Service: "b", Price: 2, Origin: "29161376"},
+				},
+			},
+		},
+		{
+			name: "test #3 - expiration kept",
+			args: args{
+				entry: responseAPI{
+					Dispatchers: []dispatcherAPI{
+						{Offers: []offer{{Modal: "a", FinalPrice: 1, Carrier: carrier{Name: "a"}, Expiration: expiration}}},
+					},
+				},
+			},
+			wantResult: data.QuoteEntry{
+				Carrier: []data.Carrier{{Name: "a", Service: "a", Price: 1, Expiration: &expiration}},
+			},
+		},
+		{
+			name: "test #4 - offer details",
+			args: args{
+				entry: responseAPI{
+					Dispatchers: []dispatcherAPI{
+						{
+							Offers: []offer{
+								{
+									Modal:          "Rodoviário",
+									TableReference: "281-PAC",
+									FinalPrice:     10000,
+									CostPrice:      9500,
+									Carrier:        carrier{Name: "CORREIOS", RegisteredNumber: "34028316000103", CompanyName: "EMPRESA BRASILEIRA DE CORREIOS E TELEGRAFOS", Logo: "https://logo"},
+									Weights:        weights{Real: 13, Cubed: 14.4, Used: 14.4},
+									HomeDelivery:   true,
+									DeliveryTime:   deliveryTime{Days: 5, EstimatedDate: "2025-03-25"},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantResult: data.QuoteEntry{
+				Carrier: []data.Carrier{
+					{
+						Name:             "CORREIOS",
+						Service:          "Rodoviário",
+						Price:            10000,
+						RegisteredNumber: "34028316000103",
+						CompanyName:      "EMPRESA BRASILEIRA DE CORREIOS E TELEGRAFOS",
+						Logo:             "https://logo",
+						TableReference:   "281-PAC",
+						Weights:          &data.Weights{Real: 13, Cubed: 14.4, Used: 14.4},
+						HomeDelivery:     true,
+						EstimatedDate:    "2025-03-25",
+						CostPrice:        money(9500),
+						Margin:           money(500),
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &freteRapidoProvider{}
+
+			gotResult := p.formatResponseAPI(tt.args.entry)
+
+			if !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("freteRapidoProvider.formatResponseAPI() = %v, want %v", gotResult, tt.wantResult)
+			}
+		})
+	}
+}
+
+func TestFreteRapidoProvider_postSimulateAPI(t *testing.T) {
+	tests := []struct {
+		name       string
+		scenario   fakefr.Scenario
+		timeout    time.Duration
+		wantErr    bool
+		wantOffers bool
+	}{
+		{name: "test #1 - valid response", scenario: fakefr.ScenarioOK, wantErr: false, wantOffers: true},
+		{name: "test #2 - slow response", scenario: fakefr.ScenarioSlow, timeout: 50 * time.Millisecond, wantErr: true},
+		{name: "test #3 - 4xx response", scenario: fakefr.ScenarioBadRequest, wantErr: true},
+		{name: "test #4 - 5xx response", scenario: fakefr.ScenarioServerError, wantErr: true},
+		{name: "test #5 - malformed json", scenario: fakefr.ScenarioMalformed, wantErr: true},
+		{name: "test #6 - empty dispatchers", scenario: fakefr.ScenarioEmptyDispatchers, wantErr: false, wantOffers: false},
+		{name: "test #7 - zero prices", scenario: fakefr.ScenarioZeroPrice, wantErr: false, wantOffers: true},
+		{name: "test #8 - unauthorized", scenario: fakefr.ScenarioUnauthorized, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakefr.NewServer(fakefr.Options{Scenario: tt.scenario})
+			defer server.Close()
+
+			p := newFakeProvider(server)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			gotResAPI, err := p.postSimulateAPI(ctx, p.buildRequestAPI(validRequestQuote()))
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("freteRapidoProvider.postSimulateAPI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			gotOffers := len(gotResAPI.Dispatchers) > 0 && len(gotResAPI.Dispatchers[0].Offers) > 0
+			if gotOffers != tt.wantOffers {
+				t.Errorf("freteRapidoProvider.postSimulateAPI() offers = %v, want %v", gotOffers, tt.wantOffers)
+			}
+
+			// request sent as expected?
+			requests := server.Handler.Requests()
+			if len(requests) != 1 || requests[0].Recipient.Zipcode != 1311000 || requests[0].Dispatchers[0].Zipcode != 29161376 {
+				t.Errorf("freteRapidoProvider.postSimulateAPI() sent %+v", requests)
+			}
+		})
+	}
+}
+
+func TestFreteRapidoProvider_postSimulateAPI_errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		scenario   fakefr.Scenario
+		timeout    time.Duration
+		wantCode   string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "test #1 - validation error", scenario: fakefr.ScenarioBadRequest, wantCode: codeUpstreamValidation, wantStatus: http.StatusUnprocessableEntity, wantBody: "recipient.zipcode: invalid zipcode"},
+		{name: "test #2 - auth error", scenario: fakefr.ScenarioUnauthorized, wantCode: codeUpstreamAuth, wantStatus: http.StatusBadGateway, wantBody: "shipper.token: invalid token"},
+		{name: "test #3 - timeout", scenario: fakefr.ScenarioSlow, timeout: 50 * time.Millisecond, wantCode: codeUpstreamTimeout, wantStatus: http.StatusGatewayTimeout},
+		{name: "test #4 - 5xx response", scenario: fakefr.ScenarioServerError, wantCode: codeUpstreamError, wantStatus: http.StatusBadGateway, wantBody: "internal server error"},
+		{name: "test #5 - malformed json", scenario: fakefr.ScenarioMalformed, wantCode: codeUpstreamError, wantStatus: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakefr.NewServer(fakefr.Options{Scenario: tt.scenario})
+			defer server.Close()
+
+			p := newFakeProvider(server)
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			_, err := p.postSimulateAPI(ctx, p.buildRequestAPI(validRequestQuote()))
+
+			gotStatus, gotCode := failureStatus(err)
+			if gotCode != tt.wantCode || gotStatus != tt.wantStatus {
+				t.Errorf("failureStatus() = %d %s, want %d %s", gotStatus, gotCode, tt.wantStatus, tt.wantCode)
+			}
+
+			// provider's body kept
+			gotBody := string(describeFailure(err).Body)
+			if tt.wantBody != "" && !strings.Contains(gotBody, tt.wantBody) {
+				t.Errorf("describeFailure() body = %s, want %s", gotBody, tt.wantBody)
+			}
+			if tt.wantBody == "" && gotBody != "" {
+				t.Errorf("describeFailure() body = %s, want none", gotBody)
+			}
+		})
+	}
+}
+
+func TestFreteRapidoProvider_Quote(t *testing.T) {
+	server := fakefr.NewServer(fakefr.Options{})
+	defer server.Close()
+
+	p := newFakeProvider(server)
+
+	gotCarriers, err := p.Quote(context.Background(), validRequestQuote())
+	if err != nil {
+		t.Fatalf("freteRapidoProvider.Quote() error = %v", err)
+	}
+
+	if len(gotCarriers) == 0 {
+		t.Fatalf("freteRapidoProvider.Quote() returned no offers")
+	}
+
+	for _, carrier := range gotCarriers {
+		if carrier.Name == "" || carrier.Service == "" {
+			t.Errorf("freteRapidoProvider.Quote() returned incomplete offer %+v", carrier)
+		}
+	}
+}
+
+func TestOffer_money(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantPrice data.Money
+		wantJSON  string
+		wantErr   bool
+	}{
+		{name: "test #1 - decimal", body: `{"final_price": 93.35}`, wantPrice: 9335, wantJSON: "93.35"},
+		{name: "test #2 - no float drift", body: `{"final_price": 0.29}`, wantPrice: 29, wantJSON: "0.29"},
+		{name: "test #3 - half up", body: `{"final_price": 41.825}`, wantPrice: 4183, wantJSON: "41.83"},
+		{name: "test #4 - integer", body: `{"final_price": 150}`, wantPrice: 15000, wantJSON: "150.00"},
+		{name: "test #5 - exponent", body: `{"final_price": 1.5e1}`, wantPrice: 1500, wantJSON: "15.00"},
+		{name: "test #6 - string", body: `{"final_price": "7.1"}`, wantPrice: 710, wantJSON: "7.10"},
+		{name: "test #7 - invalid", body: `{"final_price": "free"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotOffer offer
+			err := json.Unmarshal([]byte(tt.body), &gotOffer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("json.Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if gotOffer.FinalPrice != tt.wantPrice {
+				t.Errorf("offer.FinalPrice = %d, want %d", gotOffer.FinalPrice, tt.wantPrice)
+			}
+
+			gotJSON, _ := json.Marshal(gotOffer.FinalPrice)
+			if string(gotJSON) != tt.wantJSON {
+				t.Errorf("json.Marshal() = %s, want %s", gotJSON, tt.wantJSON)
+			}
+		})
+	}
+}
@@ -2,13 +2,25 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mtrdgs/fr/data"
+)
+
+const (
+	defaultPageSize    = 20
+	maxPageSize        = 100
+	defaultPriceWeight = 0.5
 )
 
 type jsonResponse struct {
 	Error   bool   `json:"error"`
+	Code    string `json:"code,omitempty"` // machine-readable reason of errors (ex.: upstream_timeout)
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
 }
@@ -24,12 +36,27 @@ func (app *Config) Fr(w http.ResponseWriter, r *http.Request) {
 }
 
 // Quote - handles user request to get a quote from freteapido api
+// offers can be sorted by price or deadline (?sort=), and the summary flags the recommended ones
 func (app *Config) Quote(w http.ResponseWriter, r *http.Request) {
 	requestQuote := requestQuote{}
 	payload := jsonResponse{}
 
+	// check how offers are sorted (providers' order, by default)
+	sortBy := strings.ToLower(r.URL.Query().Get("sort"))
+	if !sortings[sortBy] {
+		app.errorJSON(w, errors.New("invalid 'sort' value (price or deadline)"), http.StatusBadRequest)
+		return
+	}
+
+	// check how much price matters in the best value recommendation (0 to 1, the rest is deadline)
+	priceWeight, err := parsePriceWeight(r.URL.Query().Get("price_weight"))
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
 	// decode request
-	err := app.readJSON(w, r, &requestQuote)
+	err = app.readJSON(w, r, &requestQuote)
 	if err != nil {
 		app.errorJSON(w, err, http.StatusBadRequest)
 		return
@@ -40,6 +67,7 @@ func (app *Config) Quote(w http.ResponseWriter, r *http.Request) {
 	invalidArgs := app.checkRequest(requestQuote)
 	if len(invalidArgs) > 0 {
 		payload.Error = true
+		payload.Code = codeMissingArguments
 		payload.Message = "Missing arguments!"
 		payload.Data = invalidArgs
 
@@ -47,36 +75,177 @@ func (app *Config) Quote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// build request (needed for external api)
-	requestAPI := app.buildRequestAPI(requestQuote)
+	// the caller's tenant (its credentials reach providers through the request's context)
+	tenant := app.tenant(r)
 
-	// call freterapido api (simulate module)
-	responseAPI, err := app.postSimulateAPI(requestAPI)
-	if err != nil {
-		payload.Error = true
-		payload.Message = "Failed to connect to freterapido API"
-		payload.Data = err.Error()
+	// call every enabled provider (concurrently) and merge their offers
+	ctx, cacheStatus := withCacheStatus(r.Context())
+	quoteResult, providerErrors := app.fanOutQuote(ctx, requestQuote)
 
-		app.writeJSON(w, http.StatusBadRequest, payload)
+	// were providers' responses cached?
+	if value := cacheStatus.String(); value != "" {
+		w.Header().Set("Cache-Status", value)
+	}
+
+	if len(app.Providers) == 0 || len(providerErrors) == len(app.Providers) {
+		// status and code of the first provider's failure (providers' order)
+		err = errors.New("no quote providers enabled")
+		if len(app.Providers) > 0 {
+			err = providerErrors[app.Providers[0].Name()]
+		}
+
+		app.failJSON(w, "Failed to connect to quote providers", err, describeFailures(providerErrors))
 		return
 	}
 
-	// format response from api, to be used in mongo
-	quoteResult := app.formatResponseAPI(responseAPI)
+	// keep the tenant and the request that produced these offers
+	quoteResult.Shipper = tenant.RegisteredNumber
+	quoteResult.Request = app.normalizeRequest(requestQuote, tenant)
+
+	// apply shipper's rules (hidden carriers, max deadline, markups, discounts and free shipping)
+	rules, err := app.Repo.FindRules(tenant.RegisteredNumber)
+	if err != nil {
+		app.failJSON(w, "Failed to load rules from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+	quoteResult.Carrier = applyRules(quoteResult.Carrier, rules, quoteResult.Request)
 
 	// save result in mongo
-	err = app.Repo.Insert(quoteResult)
+	quoteResult, err = app.Repo.Insert(quoteResult)
 	if err != nil {
-		payload.Error = true
-		payload.Message = "Failed to insert into Mongo"
-		payload.Data = err.Error()
+		app.failJSON(w, "Failed to insert into Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
 
-		app.writeJSON(w, http.StatusBadRequest, payload)
+	// sorted offers (stored ones keep the providers' order)
+	quoteResult.Carrier = sortCarriers(quoteResult.Carrier, sortBy)
+
+	// done correctly! (partial failures are reported along with the offers)
+	app.writeJSON(w, http.StatusOK, responseQuote{
+		QuoteEntry: quoteResult,
+		Summary:    summarize(quoteResult.Carrier, priceWeight),
+		Origins:    groupByOrigin(quoteResult.Carrier),
+		Errors:     describeFailures(providerErrors),
+	})
+}
+
+// Quotes - lists the tenant's stored quotes (newest first), filtered by period, destination zipcode and carrier
+func (app *Config) Quotes(w http.ResponseWriter, r *http.Request) {
+	var filter data.QuoteFilter
+	var err error
+
+	query := r.URL.Query()
+
+	// only the caller's tenant quotes
+	filter.Shipper = app.shipper(r)
+
+	// period
+	filter.From, err = parseTimeParam(query.Get("from"), false)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid 'from' value"), http.StatusBadRequest)
+		return
+	}
+
+	filter.To, err = parseTimeParam(query.Get("to"), true)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid 'to' value"), http.StatusBadRequest)
+		return
+	}
+
+	if filter.From != nil && filter.To != nil && filter.From.After(*filter.To) {
+		app.errorJSON(w, errors.New("'from' must be before 'to'"), http.StatusBadRequest)
+		return
+	}
+
+	// destination and carrier
+	if zipcode := query.Get("zipcode"); zipcode != "" {
+		filter.Zipcode = normalizeZipcode(zipcode)
+	}
+	filter.Carrier = query.Get("carrier")
+
+	// page
+	filter.Cursor = query.Get("cursor")
+	filter.Limit = defaultPageSize
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.ParseInt(limit, 10, 64)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxPageSize {
+			app.errorJSON(w, fmt.Errorf("invalid 'limit' value (1 to %d)", maxPageSize), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// retrieve one extra quote, to know if there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+
+	quotes, err := app.Repo.FindAll(filter)
+	if errors.Is(err, data.ErrInvalidCursor) {
+		app.errorJSON(w, errors.New("invalid 'cursor' value"), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load quotes from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	response := responseQuotes{Quotes: quotes}
+	if int64(len(quotes)) > pageSize {
+		response.Quotes = quotes[:pageSize]
+		response.NextCursor = quotes[pageSize-1].ID.Hex()
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, response)
+}
+
+// QuoteByID - returns a single stored quote (of the caller's tenant)
+func (app *Config) QuoteByID(w http.ResponseWriter, r *http.Request) {
+	quote, err := app.Repo.FindByID(app.shipper(r), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load quote from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, quote)
+}
+
+// ValidateQuote - tells if the offers of a stored quote are still valid (so stale prices are not honored)
+func (app *Config) ValidateQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := app.Repo.FindByID(app.shipper(r), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load quote from Mongo", &storageError{Err: err}, err.Error())
 		return
 	}
 
 	// done correctly!
-	app.writeJSON(w, http.StatusOK, quoteResult)
+	app.writeJSON(w, http.StatusOK, validateQuote(quote, time.Now()))
+}
+
+// Status - shows the state of the app's dependencies (ex.: if calls to providers are cut by the circuit breaker)
+// keys of a tenant only see the quota of their own shipper
+func (app *Config) Status(w http.ResponseWriter, r *http.Request) {
+	rateLimit := app.Limiter.status()
+
+	if apiKey, _ := r.Context().Value(apiKeyContextKey{}).(data.APIKey); apiKey.Shipper != "" {
+		shippers := make([]shipperStatus, 0)
+		for _, value := range rateLimit.Shippers {
+			if value.Shipper == apiKey.Shipper {
+				shippers = append(shippers, value)
+			}
+		}
+		rateLimit.Shippers = shippers
+	}
+
+	app.writeJSON(w, http.StatusOK, responseStatus{Upstream: app.Breaker.status(), RateLimit: rateLimit})
 }
 
 // Metrics - handles the request to calc the metrics using quotes info from db
@@ -95,16 +264,415 @@ func (app *Config) Metrics(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// retrieve quotes from db
-	quotes, err := app.Repo.FindSpecific(lastQuotes)
+	// check if metrics are split in periods (day, week or month)
+	bucket := strings.ToLower(r.URL.Query().Get("bucket"))
+	if !buckets[bucket] {
+		app.errorJSON(w, errors.New("invalid 'bucket' value (day, week or month)"), http.StatusBadRequest)
+		return
+	}
+
+	// check what offers are grouped by (carrier, by default)
+	groupBy := strings.ToLower(r.URL.Query().Get("group_by"))
+	if groupBy == "" {
+		groupBy = "carrier"
+	}
+	if !groupings[groupBy] {
+		app.errorJSON(w, errors.New("invalid 'group_by' value (carrier, carrier_service, service or destination_state)"), http.StatusBadRequest)
+		return
+	}
+
+	// check how much price matters in the best value ranking (0 to 1, the rest is deadline)
+	priceWeight, err := parsePriceWeight(r.URL.Query().Get("price_weight"))
 	if err != nil {
 		app.errorJSON(w, err, http.StatusBadRequest)
 		return
 	}
 
-	// format quotes
-	responseMetrics := app.prepareMetricsResponse(quotes)
+	// check if period is set (both limits are optional)
+	from, err := parseTimeParam(r.URL.Query().Get("from"), false)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid 'from' value"), http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseTimeParam(r.URL.Query().Get("to"), true)
+	if err != nil {
+		app.errorJSON(w, errors.New("invalid 'to' value"), http.StatusBadRequest)
+		return
+	}
+
+	if from != nil && to != nil && from.After(*to) {
+		app.errorJSON(w, errors.New("'from' must be before 'to'"), http.StatusBadRequest)
+		return
+	}
+
+	// aggregate tenant's quotes in db (last ones, in the period)
+	query := data.MetricsQuery{
+		Shipper: app.shipper(r),
+		Amount:  lastQuotes,
+		From:    from,
+		To:      to,
+		Bucket:  bucket,
+		GroupBy: groupBy,
+		Policy:  app.Settings.Metrics.policy(),
+	}
+
+	stats, err := app.Repo.AggregateMetrics(query)
+	if err != nil {
+		app.failJSON(w, "Failed to aggregate metrics in Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// format stats
+	responseMetrics := app.prepareMetricsResponse(stats, query, priceWeight)
 
 	// done correctly!
 	app.writeJSON(w, http.StatusOK, responseMetrics)
 }
+
+// Rules - lists shipper's rules (by priority)
+func (app *Config) Rules(w http.ResponseWriter, r *http.Request) {
+	rules, err := app.Repo.FindRules(app.shipper(r))
+	if err != nil {
+		app.failJSON(w, "Failed to load rules from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, rules)
+}
+
+// CreateRule - stores a new shipper's rule
+func (app *Config) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var rule data.Rule
+
+	// decode request
+	err := app.readJSON(w, r, &rule)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// verify arguments of the rule's type
+	invalidArgs := checkRule(rule)
+	if len(invalidArgs) > 0 {
+		app.writeJSON(w, http.StatusBadRequest, jsonResponse{Error: true, Code: codeMissingArguments, Message: "Missing arguments!", Data: invalidArgs})
+		return
+	}
+
+	// rules always belong to the caller's tenant
+	rule.Shipper = app.shipper(r)
+
+	rule, err = app.Repo.InsertRule(rule)
+	if err != nil {
+		app.failJSON(w, "Failed to insert rule into Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusCreated, rule)
+}
+
+// RuleByID - gets a single shipper's rule
+func (app *Config) RuleByID(w http.ResponseWriter, r *http.Request) {
+	rule, err := app.Repo.FindRuleByID(app.shipper(r), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrRuleNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load rule from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, rule)
+}
+
+// UpdateRule - replaces a shipper's rule
+func (app *Config) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	var rule data.Rule
+
+	// rule must exist
+	current, err := app.Repo.FindRuleByID(app.shipper(r), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrRuleNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load rule from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// decode request
+	err = app.readJSON(w, r, &rule)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// verify arguments of the rule's type
+	invalidArgs := checkRule(rule)
+	if len(invalidArgs) > 0 {
+		app.writeJSON(w, http.StatusBadRequest, jsonResponse{Error: true, Code: codeMissingArguments, Message: "Missing arguments!", Data: invalidArgs})
+		return
+	}
+
+	rule.ID = current.ID
+	rule.Shipper = current.Shipper
+
+	rule, err = app.Repo.UpdateRule(rule)
+	if errors.Is(err, data.ErrRuleNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to update rule in Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, rule)
+}
+
+// DeleteRule - removes a shipper's rule
+func (app *Config) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	err := app.Repo.DeleteRule(app.shipper(r), chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrRuleNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to delete rule from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIKeys - lists the issued api keys (revoked ones too, without the keys themselves)
+func (app *Config) APIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := app.Repo.FindAPIKeys()
+	if err != nil {
+		app.failJSON(w, "Failed to load api keys from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, keys)
+}
+
+// CreateAPIKey - issues an api key with some scopes (the key is shown only in this response)
+func (app *Config) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req requestAPIKeyCreate
+
+	// decode request
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// verify name and scopes
+	invalidArgs := checkAPIKey(req)
+
+	// keys of a tenant need it stored
+	if req.Shipper != "" {
+		_, err = app.Repo.FindTenantByRegisteredNumber(req.Shipper)
+		if errors.Is(err, data.ErrTenantNotFound) {
+			invalidArgs = append(invalidArgs, fmt.Sprintf("Shipper is not a tenant (%s)", req.Shipper))
+		} else if err != nil {
+			app.failJSON(w, "Failed to load tenant from Mongo", &storageError{Err: err}, err.Error())
+			return
+		}
+	}
+
+	if len(invalidArgs) > 0 {
+		app.writeJSON(w, http.StatusBadRequest, jsonResponse{Error: true, Code: codeMissingArguments, Message: "Missing arguments!", Data: invalidArgs})
+		return
+	}
+
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		app.errorJSON(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	apiKey, err := app.Repo.InsertAPIKey(data.APIKey{
+		Name:    req.Name,
+		Prefix:  key[:len(apiKeyPrefix)+8],
+		Hash:    hash,
+		Scopes:  req.Scopes,
+		Shipper: req.Shipper,
+	})
+	if err != nil {
+		app.failJSON(w, "Failed to insert api key into Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusCreated, responseAPIKey{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKey - revokes an api key (requests with it are rejected from now on)
+func (app *Config) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	err := app.Repo.RevokeAPIKey(chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrAPIKeyNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to revoke api key in Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Tenants - lists stored tenants (without their tokens)
+func (app *Config) Tenants(w http.ResponseWriter, r *http.Request) {
+	tenants, err := app.Repo.FindTenants()
+	if err != nil {
+		app.failJSON(w, "Failed to load tenants from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, tenants)
+}
+
+// CreateTenant - stores a new tenant, with its freterapido credentials and warehouses
+func (app *Config) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req requestTenant
+
+	// decode request
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// verify credentials and warehouses
+	req = normalizeTenant(req)
+	invalidArgs := checkTenant(req)
+	if len(invalidArgs) > 0 {
+		app.writeJSON(w, http.StatusBadRequest, jsonResponse{Error: true, Code: codeMissingArguments, Message: "Missing arguments!", Data: invalidArgs})
+		return
+	}
+
+	tenant, err := app.Repo.InsertTenant(data.Tenant{
+		Name:             req.Name,
+		RegisteredNumber: req.RegisteredNumber,
+		Token:            req.Token,
+		PlatformCode:     req.PlatformCode,
+		Origins:          req.Origins,
+	})
+	if errors.Is(err, data.ErrTenantExists) {
+		app.errorJSON(w, err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to insert tenant into Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusCreated, tenant)
+}
+
+// TenantByID - gets a single tenant
+func (app *Config) TenantByID(w http.ResponseWriter, r *http.Request) {
+	tenant, err := app.Repo.FindTenantByID(chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrTenantNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load tenant from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, tenant)
+}
+
+// UpdateTenant - replaces a tenant's name, credentials and warehouses (its registered number is kept)
+func (app *Config) UpdateTenant(w http.ResponseWriter, r *http.Request) {
+	var req requestTenant
+
+	// tenant must exist
+	current, err := app.Repo.FindTenantByID(chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrTenantNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to load tenant from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// decode request
+	err = app.readJSON(w, r, &req)
+	if err != nil {
+		app.errorJSON(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// quotes, rules and keys belong to the registered number, so it can not change
+	req = normalizeTenant(req)
+	if req.RegisteredNumber != "" && req.RegisteredNumber != current.RegisteredNumber {
+		app.errorJSON(w, errors.New("registered number of a tenant can not be changed"), http.StatusBadRequest)
+		return
+	}
+	req.RegisteredNumber = current.RegisteredNumber
+
+	// token is kept when it is not sent
+	if req.Token == "" {
+		req.Token = current.Token
+	}
+
+	// verify credentials and warehouses
+	invalidArgs := checkTenant(req)
+	if len(invalidArgs) > 0 {
+		app.writeJSON(w, http.StatusBadRequest, jsonResponse{Error: true, Code: codeMissingArguments, Message: "Missing arguments!", Data: invalidArgs})
+		return
+	}
+
+	tenant, err := app.Repo.UpdateTenant(data.Tenant{
+		ID:               current.ID,
+		Name:             req.Name,
+		RegisteredNumber: current.RegisteredNumber,
+		Token:            req.Token,
+		PlatformCode:     req.PlatformCode,
+		Origins:          req.Origins,
+	})
+	if errors.Is(err, data.ErrTenantNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to update tenant in Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	app.writeJSON(w, http.StatusOK, tenant)
+}
+
+// DeleteTenant - removes a tenant (its keys are rejected from now on; its quotes and rules are kept)
+func (app *Config) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	err := app.Repo.DeleteTenant(chi.URLParam(r, "id"))
+	if errors.Is(err, data.ErrTenantNotFound) {
+		app.errorJSON(w, err, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.failJSON(w, "Failed to delete tenant from Mongo", &storageError{Err: err}, err.Error())
+		return
+	}
+
+	// done correctly!
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,11 +3,16 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mtrdgs/fr/data"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var testApp Config
@@ -49,3 +54,574 @@ func TestConfig_Metrics(t *testing.T) {
 		t.Errorf("expected http.StatusOK but got %d", rr.Code)
 	}
 }
+
+func TestConfig_Quote_partialFailure(t *testing.T) {
+	// call mocked repository and providers
+	repo := data.NewMongoTestRepository(nil)
+	app := Config{
+		Repo: repo,
+		Providers: []QuoteProvider{
+			&stubProvider{name: "down", err: newUpstreamError("down", http.StatusUnauthorized, []byte(`{"error":true,"message":"shipper.token: invalid token"}`), nil)},
+			&stubProvider{name: "up", carriers: []data.Carrier{{Name: "test", Service: "test", Deadline: 1, Price: 150}}},
+		},
+	}
+
+	body, _ := json.Marshal(validRequestQuote())
+
+	req, _ := http.NewRequest(http.MethodPost, "/quote", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(app.Quote)
+
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http.StatusOK but got %d", rr.Code)
+	}
+
+	var got responseQuote
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+	if len(got.Carrier) != 1 || got.Carrier[0].Provider != "up" {
+		t.Errorf("expected one offer from provider 'up' but got %v", got.Carrier)
+	}
+
+	// typed failure, as when every provider fails
+	failure := got.Errors["down"]
+	if failure.Code != codeUpstreamAuth || failure.Message != "down returned status 401" || !strings.Contains(string(failure.Body), "invalid token") {
+		t.Errorf("expected upstream auth error for provider 'down' but got %+v", got.Errors)
+	}
+}
+
+// errMongoDown - failure of the failing repository
+var errMongoDown = errors.New("server selection timeout")
+
+// failingRepo - repository whose reads and writes fail (mongo down)
+type failingRepo struct {
+	data.RepositoryPattern
+}
+
+func (r failingRepo) Insert(entry data.QuoteEntry) (data.QuoteEntry, error) {
+	return entry, errMongoDown
+}
+
+func (r failingRepo) FindByID(shipper, id string) (data.QuoteEntry, error) {
+	return data.QuoteEntry{}, errMongoDown
+}
+
+func (r failingRepo) FindAll(filter data.QuoteFilter) ([]data.QuoteEntry, error) {
+	return nil, errMongoDown
+}
+
+func (r failingRepo) AggregateMetrics(query data.MetricsQuery) ([]data.CarrierStats, error) {
+	return nil, errMongoDown
+}
+
+func (r failingRepo) InsertRule(rule data.Rule) (data.Rule, error) {
+	return rule, errMongoDown
+}
+
+func (r failingRepo) FindRules(shipper string) ([]data.Rule, error) {
+	return nil, errMongoDown
+}
+
+func (r failingRepo) FindRuleByID(shipper, id string) (data.Rule, error) {
+	return data.Rule{}, errMongoDown
+}
+
+func (r failingRepo) DeleteRule(shipper, id string) error {
+	return errMongoDown
+}
+
+func (r failingRepo) InsertAPIKey(key data.APIKey) (data.APIKey, error) {
+	return key, errMongoDown
+}
+
+func (r failingRepo) FindAPIKeys() ([]data.APIKey, error) {
+	return nil, errMongoDown
+}
+
+func (r failingRepo) RevokeAPIKey(id string) error {
+	return errMongoDown
+}
+
+func (r failingRepo) FindTenantByRegisteredNumber(registeredNumber string) (data.Tenant, error) {
+	return data.Tenant{}, errMongoDown
+}
+
+func (r failingRepo) InsertTenant(tenant data.Tenant) (data.Tenant, error) {
+	return tenant, errMongoDown
+}
+
+func (r failingRepo) FindTenants() ([]data.Tenant, error) {
+	return nil, errMongoDown
+}
+
+func (r failingRepo) FindTenantByID(id string) (data.Tenant, error) {
+	return data.Tenant{}, errMongoDown
+}
+
+func (r failingRepo) DeleteTenant(id string) error {
+	return errMongoDown
+}
+
+func TestConfig_routes_storageFailures(t *testing.T) {
+	app := Config{Repo: failingRepo{data.NewMongoTestRepository(nil)}}
+	id := primitive.NewObjectID().Hex()
+	rule := data.Rule{Name: "+10%", Type: data.RuleMarkupPercentage, Percentage: 10}
+	tenant := requestTenant{Name: "store", RegisteredNumber: "11222333000181", Token: "token", PlatformCode: "platform", Origins: []data.Origin{{Zipcode: "88010000"}}}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{name: "test #1 - list quotes", method: http.MethodGet, path: "/quotes"},
+		{name: "test #2 - get quote", method: http.MethodGet, path: "/quotes/" + id},
+		{name: "test #3 - validate quote", method: http.MethodPost, path: "/quotes/" + id + "/validate"},
+		{name: "test #4 - metrics", method: http.MethodGet, path: "/metrics"},
+		{name: "test #5 - list rules", method: http.MethodGet, path: "/rules"},
+		{name: "test #6 - create rule", method: http.MethodPost, path: "/rules", body: rule},
+		{name: "test #7 - get rule", method: http.MethodGet, path: "/rules/" + id},
+		{name: "test #8 - update rule", method: http.MethodPut, path: "/rules/" + id, body: rule},
+		{name: "test #9 - delete rule", method: http.MethodDelete, path: "/rules/" + id},
+		{name: "test #10 - list api keys", method: http.MethodGet, path: "/keys"},
+		{name: "test #11 - issue api key", method: http.MethodPost, path: "/keys", body: requestAPIKeyCreate{Name: "storefront", Scopes: []string{data.ScopeQuoteRead}}},
+		{name: "test #12 - issue tenant's api key", method: http.MethodPost, path: "/keys", body: requestAPIKeyCreate{Name: "storefront", Scopes: []string{data.ScopeQuoteRead}, Shipper: "11222333000181"}},
+		{name: "test #13 - revoke api key", method: http.MethodDelete, path: "/keys/" + id},
+		{name: "test #14 - list tenants", method: http.MethodGet, path: "/tenants"},
+		{name: "test #15 - create tenant", method: http.MethodPost, path: "/tenants", body: tenant},
+		{name: "test #16 - get tenant", method: http.MethodGet, path: "/tenants/" + id},
+		{name: "test #17 - update tenant", method: http.MethodPut, path: "/tenants/" + id, body: tenant},
+		{name: "test #18 - delete tenant", method: http.MethodDelete, path: "/tenants/" + id},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			if tt.body != nil {
+				body, _ = json.Marshal(tt.body)
+			}
+
+			req, _ := http.NewRequest(tt.method, tt.path, bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			app.routes().ServeHTTP(rr, req)
+			if rr.Code != http.StatusServiceUnavailable {
+				t.Fatalf("expected %d but got %d", http.StatusServiceUnavailable, rr.Code)
+			}
+
+			var got jsonResponse
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			if got.Code != codeStorageError {
+				t.Errorf("expected code %s but got %+v", codeStorageError, got)
+			}
+		})
+	}
+}
+
+func TestConfig_Quote_failures(t *testing.T) {
+	body, _ := json.Marshal(validRequestQuote())
+
+	up := &stubProvider{name: "up", carriers: []data.Carrier{{Name: "test", Service: "test", Deadline: 1, Price: 150}}}
+
+	tests := []struct {
+		name       string
+		repo       data.RepositoryPattern
+		providers  []QuoteProvider
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "test #1 - upstream validation error",
+			repo:       data.NewMongoTestRepository(nil),
+			providers:  []QuoteProvider{&stubProvider{name: "freterapido", err: newUpstreamError("freterapido", http.StatusBadRequest, []byte(`{"error":true}`), nil)}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   codeUpstreamValidation,
+		},
+		{
+			name:       "test #2 - upstream timeout",
+			repo:       data.NewMongoTestRepository(nil),
+			providers:  []QuoteProvider{&stubProvider{name: "slow", delay: time.Second}},
+			wantStatus: http.StatusGatewayTimeout,
+			wantCode:   codeUpstreamTimeout,
+		},
+		{
+			name:       "test #3 - upstream down",
+			repo:       data.NewMongoTestRepository(nil),
+			providers:  []QuoteProvider{&stubProvider{name: "down", err: errors.New("connection refused")}},
+			wantStatus: http.StatusBadGateway,
+			wantCode:   codeUpstreamError,
+		},
+		{
+			name:       "test #4 - storage failure",
+			repo:       failingRepo{data.NewMongoTestRepository(nil)},
+			providers:  []QuoteProvider{up},
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   codeStorageError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := Config{Repo: tt.repo, Providers: tt.providers}
+			app.Settings.ProviderTimeout = 50 * time.Millisecond
+
+			req, _ := http.NewRequest(http.MethodPost, "/quote", bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(app.Quote)
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d but got %d", tt.wantStatus, rr.Code)
+			}
+
+			var got jsonResponse
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			if !got.Error || got.Code != tt.wantCode {
+				t.Errorf("expected error code %s but got %+v", tt.wantCode, got)
+			}
+		})
+	}
+}
+
+func TestConfig_Quote_sort(t *testing.T) {
+	// call mocked repository and a provider with unsorted offers
+	app := Config{
+		Repo: data.NewMongoTestRepository(nil),
+		Providers: []QuoteProvider{
+			&stubProvider{name: "up", carriers: []data.Carrier{
+				{Name: "CORREIOS", Service: "Rodoviário", Deadline: 5, Price: 10371},
+				{Name: "AZUL CARGO", Service: "Aéreo", Deadline: 2, Price: 4182},
+				{Name: "SEDEX", Service: "Aéreo", Deadline: 1, Price: 18575},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		wantStatus    int
+		wantCarriers  []string
+		wantBestValue string
+	}{
+		{name: "test #1 - providers' order", query: "", wantStatus: http.StatusOK, wantCarriers: []string{"CORREIOS", "AZUL CARGO", "SEDEX"}, wantBestValue: "AZUL CARGO"},
+		{name: "test #2 - by price", query: "?sort=price", wantStatus: http.StatusOK, wantCarriers: []string{"AZUL CARGO", "CORREIOS", "SEDEX"}, wantBestValue: "AZUL CARGO"},
+		{name: "test #3 - by deadline", query: "?sort=deadline", wantStatus: http.StatusOK, wantCarriers: []string{"SEDEX", "AZUL CARGO", "CORREIOS"}, wantBestValue: "AZUL CARGO"},
+		{name: "test #4 - deadline only", query: "?price_weight=0", wantStatus: http.StatusOK, wantCarriers: []string{"CORREIOS", "AZUL CARGO", "SEDEX"}, wantBestValue: "SEDEX"},
+		{name: "test #5 - invalid sort", query: "?sort=name", wantStatus: http.StatusBadRequest},
+		{name: "test #6 - invalid price weight", query: "?price_weight=2", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(validRequestQuote())
+
+			req, _ := http.NewRequest(http.MethodPost, "/quote"+tt.query, bytes.NewReader(body))
+			rr := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(app.Quote)
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d but got %d", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got responseQuote
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			gotCarriers := make([]string, 0, len(got.Carrier))
+			for _, carrier := range got.Carrier {
+				gotCarriers = append(gotCarriers, carrier.Name)
+			}
+
+			if !reflect.DeepEqual(gotCarriers, tt.wantCarriers) {
+				t.Errorf("carriers = %v, want %v", gotCarriers, tt.wantCarriers)
+			}
+
+			if got.Summary == nil || got.Summary.Cheapest.Name != "AZUL CARGO" || got.Summary.Fastest.Name != "SEDEX" || got.Summary.BestValue.Name != tt.wantBestValue {
+				t.Errorf("summary = %+v, want cheapest AZUL CARGO, fastest SEDEX and best value %s", got.Summary, tt.wantBestValue)
+			}
+		})
+	}
+}
+
+func TestConfig_Quotes(t *testing.T) {
+	// call mocked repository, with a few stored quotes
+	repo := data.NewMongoTestRepository(nil)
+	for _, zipcode := range []string{"01311000", "88010000", "01311000"} {
+		_, _ = repo.Insert(data.QuoteEntry{
+			Carrier: []data.Carrier{{Name: "CORREIOS", Price: 1000}},
+			Request: &data.QuoteRequest{Destination: data.Destination{Zipcode: zipcode}},
+		})
+	}
+	testApp.Repo = repo
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatus     int
+		wantQuotes     int
+		wantNextCursor bool
+	}{
+		{name: "test #1 - every quote", query: "", wantStatus: http.StatusOK, wantQuotes: 4},
+		{name: "test #2 - first page", query: "?limit=2", wantStatus: http.StatusOK, wantQuotes: 2, wantNextCursor: true},
+		{name: "test #3 - by zipcode", query: "?zipcode=01311-000", wantStatus: http.StatusOK, wantQuotes: 2},
+		{name: "test #4 - by carrier", query: "?carrier=correios", wantStatus: http.StatusOK, wantQuotes: 3},
+		{name: "test #5 - by period", query: "?to=2000-01-01", wantStatus: http.StatusOK, wantQuotes: 0},
+		{name: "test #6 - invalid date", query: "?from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "test #7 - invalid limit", query: "?limit=1000", wantStatus: http.StatusBadRequest},
+		{name: "test #8 - invalid cursor", query: "?cursor=abc", wantStatus: http.StatusBadRequest},
+		{name: "test #9 - from after to", query: "?from=2025-02-01&to=2025-01-01", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/quotes"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(testApp.Quotes)
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d but got %d", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got responseQuotes
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			if len(got.Quotes) != tt.wantQuotes {
+				t.Errorf("expected %d quotes but got %d", tt.wantQuotes, len(got.Quotes))
+			}
+
+			if (got.NextCursor != "") != tt.wantNextCursor {
+				t.Errorf("expected next cursor = %v but got %q", tt.wantNextCursor, got.NextCursor)
+			}
+		})
+	}
+
+	// following the cursor returns the remaining quotes
+	req, _ := http.NewRequest(http.MethodGet, "/quotes?limit=3", nil)
+	rr := httptest.NewRecorder()
+	testApp.Quotes(rr, req)
+
+	var firstPage, secondPage responseQuotes
+	_ = json.Unmarshal(rr.Body.Bytes(), &firstPage)
+
+	req, _ = http.NewRequest(http.MethodGet, "/quotes?limit=3&cursor="+firstPage.NextCursor, nil)
+	rr = httptest.NewRecorder()
+	testApp.Quotes(rr, req)
+	_ = json.Unmarshal(rr.Body.Bytes(), &secondPage)
+
+	if len(secondPage.Quotes) != 1 || secondPage.NextCursor != "" {
+		t.Errorf("expected 1 quote in the last page but got %d (next cursor %q)", len(secondPage.Quotes), secondPage.NextCursor)
+	}
+}
+
+func TestConfig_QuoteByID(t *testing.T) {
+	// call mocked repository
+	repo := data.NewMongoTestRepository(nil)
+	stored, _ := repo.Insert(data.QuoteEntry{Carrier: []data.Carrier{{Name: "test"}}})
+	testApp.Repo = repo
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "test #1 - existing quote", id: stored.ID.Hex(), wantStatus: http.StatusOK},
+		{name: "test #2 - unknown quote", id: "000000000000000000000000", wantStatus: http.StatusNotFound},
+		{name: "test #3 - invalid id", id: "abc", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/quotes/"+tt.id, nil)
+			rr := httptest.NewRecorder()
+
+			// route through chi, so {id} is set
+			testApp.routes().ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected %d but got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateQuote(t *testing.T) {
+	// call mocked repository
+	repo := data.NewMongoTestRepository(nil)
+	future, past := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+	valid, _ := repo.Insert(data.QuoteEntry{Carrier: []data.Carrier{{Name: "a", Expiration: &future}, {Name: "b", Expiration: &future}}})
+	expired, _ := repo.Insert(data.QuoteEntry{Carrier: []data.Carrier{{Name: "a", Expiration: &future}, {Name: "b", Expiration: &past}}})
+	unknown, _ := repo.Insert(data.QuoteEntry{Carrier: []data.Carrier{{Name: "a"}}})
+	testApp.Repo = repo
+
+	tests := []struct {
+		name         string
+		id           string
+		wantStatus   int
+		wantValid    bool
+		wantStatuses []string
+	}{
+		{name: "test #1 - every offer valid", id: valid.ID.Hex(), wantStatus: http.StatusOK, wantValid: true, wantStatuses: []string{"valid", "valid"}},
+		{name: "test #2 - expired offer", id: expired.ID.Hex(), wantStatus: http.StatusOK, wantValid: false, wantStatuses: []string{"valid", "expired"}},
+		{name: "test #3 - offer without expiration", id: unknown.ID.Hex(), wantStatus: http.StatusOK, wantValid: false, wantStatuses: []string{"unknown"}},
+		{name: "test #4 - unknown quote", id: "000000000000000000000000", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/quotes/"+tt.id+"/validate", nil)
+			rr := httptest.NewRecorder()
+
+			// route through chi, so {id} is set
+			testApp.routes().ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d but got %d", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got responseValidation
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+
+			if got.ID != tt.id || got.Valid != tt.wantValid {
+				t.Errorf("got id %s valid %v, want %s %v", got.ID, got.Valid, tt.id, tt.wantValid)
+			}
+
+			gotStatuses := make([]string, 0, len(got.Offers))
+			for _, offer := range got.Offers {
+				gotStatuses = append(gotStatuses, offer.Status)
+			}
+			if !reflect.DeepEqual(gotStatuses, tt.wantStatuses) {
+				t.Errorf("got statuses %v, want %v", gotStatuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestConfig_Metrics_period(t *testing.T) {
+	// call mocked repository
+	repo := data.NewMongoTestRepository(nil)
+	testApp.Repo = repo
+
+	tests := []struct {
+		name        string
+		query       string
+		wantStatus  int
+		wantResults int
+	}{
+		{name: "test #1 - period with quotes", query: "?from=2000-01-01&to=" + time.Now().Add(time.Hour).Format(time.RFC3339), wantStatus: http.StatusOK, wantResults: 1},
+		{name: "test #2 - period without quotes", query: "?to=2000-01-01", wantStatus: http.StatusOK, wantResults: 0},
+		{name: "test #3 - combined with last_quotes", query: "?from=2000-01-01&last_quotes=1", wantStatus: http.StatusOK, wantResults: 1},
+		{name: "test #4 - invalid date", query: "?from=last-week", wantStatus: http.StatusBadRequest},
+		{name: "test #5 - from after to", query: "?from=2000-01-02&to=2000-01-01", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/metrics"+tt.query, nil)
+			rr := httptest.NewRecorder()
+
+			handler := http.HandlerFunc(testApp.Metrics)
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d but got %d", tt.wantStatus, rr.Code)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var got responseMetrics
+			_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+			if got.Metrics[0].ResultsPerCarrier["test"] != tt.wantResults {
+				t.Errorf("expected %d results but got %d", tt.wantResults, got.Metrics[0].ResultsPerCarrier["test"])
+			}
+		})
+	}
+}
+
+func TestConfig_Rules(t *testing.T) {
+	// call mocked repository and a provider (rules are applied to its offers)
+	app := Config{
+		Repo: data.NewMongoTestRepository(nil),
+		Providers: []QuoteProvider{
+			&stubProvider{name: "up", carriers: []data.Carrier{
+				{Name: "CORREIOS", Deadline: 5, Price: 10000},
+				{Name: "AZUL CARGO", Deadline: 2, Price: 4182},
+			}},
+		},
+	}
+	app.Settings.FreteRapido.RegisteredNumber = "25438296000158"
+	routes := app.routes()
+
+	// send - calls a route, returning its status and decoding its response
+	send := func(method, path string, body any, response any) int {
+		payload, _ := json.Marshal(body)
+
+		req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+		rr := httptest.NewRecorder()
+
+		routes.ServeHTTP(rr, req)
+		if response != nil {
+			_ = json.Unmarshal(rr.Body.Bytes(), response)
+		}
+
+		return rr.Code
+	}
+
+	// create
+	var created data.Rule
+	status := send(http.MethodPost, "/rules", map[string]any{"name": "+10%", "type": "markup_percentage", "percentage": 10}, &created)
+	if status != http.StatusCreated || created.ID.IsZero() || created.Shipper != "25438296000158" {
+		t.Fatalf("create rule: status %d, rule %+v", status, created)
+	}
+
+	if status = send(http.MethodPost, "/rules", map[string]any{"name": "hide", "type": "hide_carrier"}, nil); status != http.StatusBadRequest {
+		t.Errorf("create invalid rule: expected %d but got %d", http.StatusBadRequest, status)
+	}
+
+	// list and get
+	var rules []data.Rule
+	if status = send(http.MethodGet, "/rules", nil, &rules); status != http.StatusOK || len(rules) != 1 {
+		t.Errorf("list rules: status %d, rules %+v", status, rules)
+	}
+
+	if status = send(http.MethodGet, "/rules/"+created.ID.Hex(), nil, nil); status != http.StatusOK {
+		t.Errorf("get rule: expected %d but got %d", http.StatusOK, status)
+	}
+
+	// update
+	var updated data.Rule
+	status = send(http.MethodPut, "/rules/"+created.ID.Hex(), map[string]any{"name": "hide azul", "type": "hide_carrier", "carriers": []string{"AZUL CARGO"}}, &updated)
+	if status != http.StatusOK || updated.ID != created.ID || updated.Type != data.RuleHideCarrier || !updated.CreatedAt.Equal(*created.CreatedAt) {
+		t.Errorf("update rule: status %d, rule %+v", status, updated)
+	}
+
+	// quote with the rule applied
+	var quote responseQuote
+	if status = send(http.MethodPost, "/quote", validRequestQuote(), &quote); status != http.StatusOK || len(quote.Carrier) != 1 || quote.Carrier[0].Name != "CORREIOS" {
+		t.Errorf("quote with rules: status %d, offers %+v", status, quote.Carrier)
+	}
+
+	// delete
+	if status = send(http.MethodDelete, "/rules/"+created.ID.Hex(), nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete rule: expected %d but got %d", http.StatusNoContent, status)
+	}
+
+	if status = send(http.MethodGet, "/rules/"+created.ID.Hex(), nil, nil); status != http.StatusNotFound {
+		t.Errorf("get deleted rule: expected %d but got %d", http.StatusNotFound, status)
+	}
+}
@@ -1,33 +1,19 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mtrdgs/fr/data"
 )
 
-// ResponseMetrics -
-type responseMetrics struct {
-	Metrics []metric `json:"metrics"`
-}
-
-// Metric -
-type metric struct {
-	ResultsPerCarrier    map[string]int     `json:"results_per_carrier"`
-	TotalPricePerCarrier map[string]float64 `json:"total_price_per_carrier"`
-	AvgPricePerCarrier   map[string]float64 `json:"avg_price_per_carrier"`
-	CheapestFreight      map[string]float64 `json:"cheapest_freight"`
-	PriciestFreight      map[string]float64 `json:"priciest_freight"`
-}
-
 // writeJSON - writes a json response to client (from a response's status code and data)
 func (app *Config) writeJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
 	out, err := json.Marshal(data)
@@ -82,11 +68,24 @@ func (app *Config) errorJSON(w http.ResponseWriter, err error, status ...int) er
 
 	var payload jsonResponse
 	payload.Error = true
+	payload.Code = strings.ReplaceAll(strings.ToLower(http.StatusText(statusCode)), " ", "_") // ex.: not_found
 	payload.Message = err.Error()
 
 	return app.writeJSON(w, statusCode, payload)
 }
 
+// failJSON - sends a json error response to client, with the status and code mapped from a failure (of providers or storage)
+func (app *Config) failJSON(w http.ResponseWriter, message string, err error, data any) error {
+	status, code := failureStatus(err)
+
+	// when to try again (whole seconds)
+	if wait, ok := retryAfter(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(wait.Seconds(), 1)))))
+	}
+
+	return app.writeJSON(w, status, jsonResponse{Error: true, Code: code, Message: message, Data: data})
+}
+
 // checkRequest - verifies if user's request has all needed arguments
 func (app *Config) checkRequest(req requestQuote) (args []string) {
 	args = make([]string, 0)
@@ -96,6 +95,13 @@ func (app *Config) checkRequest(req requestQuote) (args []string) {
 		args = append(args, "Zipcode is required")
 	}
 
+	// valid origins? (optional, default: every configured warehouse)
+	for key, value := range req.Origins {
+		if _, err := strconv.Atoi(value.Zipcode); err != nil {
+			args = append(args, fmt.Sprintf("Zipcode is invalid for Origin[%d]", key))
+		}
+	}
+
 	// contains volume?
 	if len(req.Volumes) == 0 {
 		args = append(args, "Volumes are required")
@@ -142,142 +148,128 @@ func (app *Config) checkRequest(req requestQuote) (args []string) {
 	return args
 }
 
-// buildRequestAPI - creates request to be used at freterapido api from user's input
-func (app *Config) buildRequestAPI(reqQuote requestQuote) (reqAPI requestAPI) {
-	// shipper
-	reqAPI.Shipper.RegisteredNumber = os.Getenv("REGISTERED_NUMBER")
-	reqAPI.Shipper.Token = os.Getenv("TOKEN")
-	reqAPI.Shipper.PlatformCode = os.Getenv("PLATFORM_CODE")
-
-	// recipient
-	reqAPI.Recipient.Type = 0        // fixed
-	reqAPI.Recipient.Country = "BRA" // fixed
-	reqAPI.Recipient.Zipcode, _ = strconv.Atoi(reqQuote.Recipient.Address.Zipcode)
-
-	// dispatchers
-	var dispatcher dispatcher
-	dispatcher.RegisteredNumber = os.Getenv("REGISTERED_NUMBER")
-	dispatcher.Zipcode, _ = strconv.Atoi(os.Getenv("ZIPCODE"))
-	for _, value := range reqQuote.Volumes {
-		var volume volumeApi
-		volume.Amount = value.Amount
-		volume.Category = strconv.Itoa(value.Category)
-		volume.Height = value.Height
-		volume.Length = value.Length
-		volume.Price = value.Price
-		volume.Sku = value.Sku
-		volume.UnitaryPrice = value.Price / value.Amount
-		volume.UnitaryWeight = value.UnitaryWeight
-		volume.Width = value.Width
-
-		dispatcher.Volumes = append(dispatcher.Volumes, volume)
-	}
-	reqAPI.Dispatchers = append(reqAPI.Dispatchers, dispatcher)
+// normalizeRequest - converts user's request into the request stored along with its quote
+func (app *Config) normalizeRequest(req requestQuote, tenant data.Tenant) *data.QuoteRequest {
+	destination := normalizeZipcode(req.Recipient.Address.Zipcode)
 
-	// simulation type
-	reqAPI.SimulationType = append(reqAPI.SimulationType, 0) // fixed
+	normalized := &data.QuoteRequest{
+		Destination: data.Destination{Zipcode: destination, State: stateFromZipcode(destination)},
+		Origins:     make([]string, 0),
+		Volumes:     make([]data.Volume, 0, len(req.Volumes)),
+	}
 
-	// returns
-	reqAPI.Returns.Composition = false
-	reqAPI.Returns.Volumes = false
-	reqAPI.Returns.AppliedRules = false
+	// origins (requested or every warehouse of the tenant)
+	if len(req.Origins) > 0 {
+		for _, value := range req.Origins {
+			normalized.Origins = append(normalized.Origins, normalizeZipcode(value.Zipcode))
+		}
+	} else {
+		for _, value := range tenant.Origins {
+			normalized.Origins = append(normalized.Origins, normalizeZipcode(value.Zipcode))
+		}
+	}
 
-	return reqAPI
-}
+	// volumes and package profile
+	for _, value := range req.Volumes {
+		normalized.Volumes = append(normalized.Volumes, data.Volume{
+			Category:      value.Category,
+			Amount:        value.Amount,
+			UnitaryWeight: value.UnitaryWeight,
+			Price:         value.Price,
+			Sku:           value.Sku,
+			Height:        value.Height,
+			Width:         value.Width,
+			Length:        value.Length,
+		})
 
-// postSimulateAPI - calls freterapido api
-func (app *Config) postSimulateAPI(reqAPI requestAPI) (resAPI responseAPI, err error) {
-	// build request
-	payload, err := json.Marshal(reqAPI)
-	if err != nil {
-		return resAPI, err
+		normalized.TotalWeight += float64(value.UnitaryWeight * value.Amount)
+		normalized.CubicVolume += value.Height * value.Width * value.Length * float64(value.Amount)
+		normalized.DeclaredValue += data.Money(value.Price) * 100
 	}
 
-	req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(payload))
+	// avoid float noise (ex.: 0.20800000000000002)
+	normalized.CubicVolume = math.Round(normalized.CubicVolume*1e6) / 1e6
+
+	return normalized
+}
+
+// normalizeZipcode - keeps only the digits of a zipcode, with its 8 digits (ex.: "01311-000" and "1311000" -> "01311000")
+func normalizeZipcode(zipcode string) string {
+	number, err := strconv.Atoi(strings.ReplaceAll(strings.TrimSpace(zipcode), "-", ""))
 	if err != nil {
-		return resAPI, err
+		return strings.TrimSpace(zipcode)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+	return formatZipcode(number)
+}
 
-	// send request
-	res, err := app.Client.Do(req)
-	if err != nil {
-		return resAPI, err
+// parseTimeParam - parses a querystring date (RFC 3339 or YYYY-MM-DD), returning nil when it is not set
+// dates without time are the start of the day, or its end when endOfDay is true (so ranges are inclusive)
+func parseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return resAPI, errors.New("appi returned a non-200 status code")
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
 	}
 
-	// decode response
-	err = json.NewDecoder(res.Body).Decode(&resAPI)
+	parsed, err := time.Parse(time.DateOnly, value)
 	if err != nil {
-		return resAPI, fmt.Errorf("failed to decode response: %w", err)
+		return nil, err
 	}
 
-	return resAPI, nil
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	return &parsed, nil
 }
 
-// formatResponseAPI - returns a json to be used in mongo insert operation, using info from freterapido api response
-func (app *Config) formatResponseAPI(entry responseAPI) (result data.QuoteEntry) {
-	// has dispatchers?
-	if len(entry.Dispatchers) == 0 {
-		return result
+// parsePriceWeight - parses how much price matters in best value rankings (0 to 1), defaulting to defaultPriceWeight
+func parsePriceWeight(value string) (float64, error) {
+	if value == "" {
+		return defaultPriceWeight, nil
 	}
 
-	// format response from api
-	for _, value := range entry.Dispatchers[0].Offers {
-		result.Carrier = append(result.Carrier, data.Carrier{
-			Name:     value.Carrier.Name,
-			Service:  value.Modal,
-			Deadline: value.CarrierOriginalDeliveryTime.Days,
-			Price:    value.FinalPrice,
-		})
+	priceWeight, err := strconv.ParseFloat(value, 64)
+	if err != nil || priceWeight < 0 || priceWeight > 1 {
+		return 0, errors.New("invalid 'price_weight' value (0 to 1)")
 	}
 
-	return result
+	return priceWeight, nil
 }
 
-// prepareMetricsResponse - calcs the info from quotes (stored in the db) and formats it as readable json to send to client"
-func (app *Config) prepareMetricsResponse(quotes []data.QuoteEntry) responseMetrics {
-	// create map to store metrics
-	mapMetrics := metric{
-		ResultsPerCarrier:    make(map[string]int),
-		TotalPricePerCarrier: make(map[string]float64),
-		AvgPricePerCarrier:   make(map[string]float64),
-		CheapestFreight:      make(map[string]float64),
-		PriciestFreight:      make(map[string]float64),
+// validateQuote - checks which offers of a stored quote are still valid (not expired) at a moment
+// offers stored without expiration can not be trusted, so they make the quote invalid too
+func validateQuote(quote data.QuoteEntry, now time.Time) responseValidation {
+	validation := responseValidation{
+		ID:        quote.ID.Hex(),
+		Valid:     len(quote.Carrier) > 0,
+		CheckedAt: now,
+		Offers:    make([]offerValidation, 0, len(quote.Carrier)),
 	}
 
-	// iterate over quotes
-	for _, quote := range quotes {
-		for _, carrier := range quote.Carrier {
-			// total results
-			mapMetrics.ResultsPerCarrier[carrier.Name]++
-
-			// total price
-			mapMetrics.TotalPricePerCarrier[carrier.Name] = (mapMetrics.TotalPricePerCarrier[carrier.Name] + carrier.Price)
-			mapMetrics.TotalPricePerCarrier[carrier.Name] = float64(int(mapMetrics.TotalPricePerCarrier[carrier.Name]*100)) / 100
-
-			// avg price
-			mapMetrics.AvgPricePerCarrier[carrier.Name] = mapMetrics.TotalPricePerCarrier[carrier.Name] / float64(mapMetrics.ResultsPerCarrier[carrier.Name])
-			mapMetrics.AvgPricePerCarrier[carrier.Name] = float64(int(mapMetrics.AvgPricePerCarrier[carrier.Name]*100)) / 100
-
-			// cheapest freight
-			if mapMetrics.CheapestFreight[carrier.Name] == 0 || carrier.Price < mapMetrics.CheapestFreight[carrier.Name] {
-				mapMetrics.CheapestFreight[carrier.Name] = carrier.Price
-			}
-
-			// priciest freight
-			if mapMetrics.PriciestFreight[carrier.Name] == 0 || carrier.Price > mapMetrics.PriciestFreight[carrier.Name] {
-				mapMetrics.PriciestFreight[carrier.Name] = carrier.Price
-			}
+	for _, carrier := range quote.Carrier {
+		status := "valid"
+		switch {
+		case carrier.Expiration == nil:
+			status = "unknown"
+		case !carrier.Expiration.After(now):
+			status = "expired"
+		}
+
+		if status != "valid" {
+			validation.Valid = false
 		}
+
+		validation.Offers = append(validation.Offers, offerValidation{Carrier: carrier, Status: status})
 	}
 
-	// append metrics to response
-	return responseMetrics{Metrics: []metric{mapMetrics}}
+	return validation
+}
+
+// shipper - registered number of the caller's tenant, which quotes, rules and metrics belong to
+func (app *Config) shipper(r *http.Request) string {
+	return app.tenant(r).RegisteredNumber
 }
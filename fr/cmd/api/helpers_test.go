@@ -133,7 +133,17 @@ func TestConfig_errorJSON(t *testing.T) {
 			},
 			wantErr:    false,
 			wantStatus: http.StatusBadGateway,
-			wantBody:   `{"error":true,"message":"error"}`,
+			wantBody:   `{"error":true,"code":"bad_gateway","message":"error"}`,
+		},
+		{
+			name: "test #2 - default status",
+			args: args{
+				w:   httptest.NewRecorder(),
+				err: errors.New("error"),
+			},
+			wantErr:    false,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":true,"code":"bad_request","message":"error"}`,
 		},
 	}
 	for _, tt := range tests {
@@ -221,136 +231,62 @@ func TestConfig_checkRequest(t *testing.T) {
 	}
 }
 
-func TestConfig_buildRequestAPI(t *testing.T) {
+func TestConfig_normalizeRequest(t *testing.T) {
 	type args struct {
-		reqQuote requestQuote
+		req requestQuote
 	}
 	tests := []struct {
-		name       string
-		args       args
-		wantReqAPI requestAPI
+		name           string
+		args           args
+		wantNormalized *data.QuoteRequest
 	}{
 		{
-			name: "test #1 - valid request",
+			name: "test #1 - configured origins",
 			args: args{
-				reqQuote: requestQuote{
-					Recipient: recipientQuote{
-						Address: address{
-							Zipcode: "12345",
-						},
-					},
+				req: requestQuote{
+					Recipient: recipientQuote{Address: address{Zipcode: "01311-000"}},
 					Volumes: []volume{
-						{
-							Category: 1,
-							Amount:   1,
-							Price:    100.0,
-							Sku:      "SKU123",
-							Height:   10.0,
-							Width:    5.0,
-							Length:   20.0,
-						},
+						{Category: 7, Amount: 1, UnitaryWeight: 5, Price: 349, Sku: "abc-teste-123", Height: 0.2, Width: 0.2, Length: 0.2},
+						{Category: 7, Amount: 2, UnitaryWeight: 4, Price: 556, Sku: "abc-teste-527", Height: 0.4, Width: 0.5, Length: 0.5},
 					},
 				},
 			},
-			wantReqAPI: requestAPI{
-				Recipient: recipientApi{
-					Type:    0,
-					Country: "BRA",
-					Zipcode: 12345,
-				},
-				Dispatchers: []dispatcher{
-					{
-						RegisteredNumber: "",
-						Zipcode:          12345,
-						Volumes: []volumeApi{
-							{
-								Category:      "1",
-								Amount:        1,
-								UnitaryWeight: 0,
-								Price:         100.0,
-								UnitaryPrice:  100,
-								Sku:           "SKU123",
-								Height:        10.0,
-								Width:         5.0,
-								Length:        20.0,
-							},
-						},
-					},
-				},
-				SimulationType: []int{0},
-				Returns: returns{
-					Composition:  false,
-					Volumes:      false,
-					AppliedRules: false,
+			wantNormalized: &data.QuoteRequest{
+				Destination: data.Destination{Zipcode: "01311000", State: "SP"},
+				Origins:     []string{"29161376"},
+				Volumes: []data.Volume{
+					{Category: 7, Amount: 1, UnitaryWeight: 5, Price: 349, Sku: "abc-teste-123", Height: 0.2, Width: 0.2, Length: 0.2},
+					{Category: 7, Amount: 2, UnitaryWeight: 4, Price: 556, Sku: "abc-teste-527", Height: 0.4, Width: 0.5, Length: 0.5},
 				},
+				TotalWeight:   13,
+				CubicVolume:   0.208,
+				DeclaredValue: 90500, // cents
 			},
 		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			app := &Config{}
-
-			gotReqAPI := app.buildRequestAPI(tt.args.reqQuote)
-
-			if !reflect.DeepEqual(gotReqAPI, tt.wantReqAPI) {
-				t.Errorf("Config.buildRequestAPI() = %v, want %v", gotReqAPI, tt.wantReqAPI)
-			}
-		})
-	}
-}
-
-func TestConfig_formatResponseAPI(t *testing.T) {
-	type args struct {
-		entry responseAPI
-	}
-	tests := []struct {
-		name       string
-		args       args
-		wantResult data.QuoteEntry
-	}{
 		{
-			name: "test #1 - valid json",
+			name: "test #2 - requested origins",
 			args: args{
-				entry: responseAPI{
-					Dispatchers: []dispatcherAPI{
-						{
-							ID: "test",
-							Offers: []offer{
-								{
-									Modal:      "test",
-									FinalPrice: 1.5,
-									Carrier: carrier{
-										Name: "test",
-									},
-									CarrierOriginalDeliveryTime: carrierOriginalDeliveryTime{
-										Days: 1,
-									},
-								},
-							},
-						},
-					},
+				req: requestQuote{
+					Recipient: recipientQuote{Address: address{Zipcode: "88010000"}},
+					Origins:   []origin{{Zipcode: "1311000"}},
 				},
 			},
-			wantResult: data.QuoteEntry{
-				Carrier: []data.Carrier{
-					{
-						Name:     "test",
-						Service:  "test",
-						Deadline: 1,
-						Price:    1.5,
-					},
-				},
+			wantNormalized: &data.QuoteRequest{
+				Destination: data.Destination{Zipcode: "88010000", State: "SC"},
+				Origins:     []string{"01311000"},
+				Volumes:     []data.Volume{},
 			},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			app := &Config{}
+			app.Settings.FreteRapido.Zipcode = "29161376"
 
-			gotResult := app.formatResponseAPI(tt.args.entry)
+			gotNormalized := app.normalizeRequest(tt.args.req, app.defaultTenant())
 
-			if !reflect.DeepEqual(gotResult, tt.wantResult) {
-				t.Errorf("Config.formatResponseAPI() = %v, want %v", gotResult, tt.wantResult)
+			if !reflect.DeepEqual(gotNormalized, tt.wantNormalized) {
+				t.Errorf("Config.normalizeRequest() = %+v, want %+v", gotNormalized, tt.wantNormalized)
 			}
 		})
 	}
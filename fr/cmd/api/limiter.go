@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// throttledError - returned (without calling the upstream) when a shipper's quota is used up and its queue is full
+type throttledError struct {
+	RetryAfter time.Duration // when a new call would be allowed
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("rate limit reached, retry after %s", e.RetryAfter)
+}
+
+// credentialContextKey - context key of the shipper credential a call to the upstream is charged to
+type credentialContextKey struct{}
+
+// credential - shipper credential whose quota a call uses
+type credential struct {
+	Shipper string // registered number
+	Token   string
+}
+
+// withCredential - context charging the calls made with it to a shipper credential (every attempt takes a token)
+func withCredential(ctx context.Context, shipper, token string) context.Context {
+	return context.WithValue(ctx, credentialContextKey{}, credential{Shipper: shipper, Token: token})
+}
+
+// credentialFromContext - shipper credential of a call (false when it is not limited)
+func credentialFromContext(ctx context.Context) (credential, bool) {
+	value, ok := ctx.Value(credentialContextKey{}).(credential)
+	return value, ok
+}
+
+// rateLimiter - token bucket per shipper credential, so calls to the upstream respect its quota
+// calls beyond the quota wait in a bounded queue; when it is full (or the wait would outlast the call), they are throttled
+type rateLimiter struct {
+	Rate  float64 // calls per second (0 to disable)
+	Burst int     // calls allowed at once
+	Queue int     // calls waiting for a token, per credential
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket // by token
+	now     func() time.Time        // overridden by tests
+}
+
+// tokenBucket - quota of a shipper credential
+// tokens go below zero while calls wait for them (each waiting call has reserved its token)
+type tokenBucket struct {
+	Shipper string
+	tokens  float64
+	last    time.Time
+	waiting int
+
+	allowed   int64
+	delayed   int64
+	throttled int64
+}
+
+// limiterStatus - quota usage per shipper (shown in /status)
+type limiterStatus struct {
+	Rate     float64         `json:"rate"` // calls per second (0: disabled)
+	Burst    int             `json:"burst"`
+	Queue    int             `json:"queue"`
+	Shippers []shipperStatus `json:"shippers,omitempty"`
+}
+
+// shipperStatus - calls of a shipper credential: allowed right away, delayed (queued) and throttled (rejected)
+type shipperStatus struct {
+	Shipper   string `json:"shipper"`
+	Waiting   int    `json:"waiting"`
+	Allowed   int64  `json:"allowed"`
+	Delayed   int64  `json:"delayed"`
+	Throttled int64  `json:"throttled"`
+}
+
+// newRateLimiter - creates a rate limiter (from settings)
+func newRateLimiter(s rateLimitSettings) *rateLimiter {
+	return &rateLimiter{Rate: s.Rate, Burst: s.Burst, Queue: s.Queue}
+}
+
+// Wait - takes a token of a shipper credential, waiting for it in the queue when there is none
+func (l *rateLimiter) Wait(ctx context.Context, shipper, token string) error {
+	if l == nil || l.Rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	bucket := l.bucket(shipper, token)
+
+	// a token available?
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.allowed++
+		l.mu.Unlock()
+		return nil
+	}
+
+	// when the next token is available
+	wait := l.duration(1 - bucket.tokens)
+
+	// queue full, or the call would be over before its turn?
+	deadline, ok := ctx.Deadline()
+	if bucket.waiting >= l.Queue || (ok && l.clock().Add(wait).After(deadline)) {
+		bucket.throttled++
+		l.mu.Unlock()
+		return &throttledError{RetryAfter: wait}
+	}
+
+	// reserve a token and wait for it
+	bucket.tokens--
+	bucket.waiting++
+	bucket.delayed++
+	l.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		l.mu.Lock()
+		bucket.waiting--
+		l.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		// give the token back to the ones behind
+		l.mu.Lock()
+		bucket.waiting--
+		bucket.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// status - quota usage of every shipper credential
+func (l *rateLimiter) status() limiterStatus {
+	if l == nil {
+		return limiterStatus{}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	status := limiterStatus{Rate: l.Rate, Burst: l.Burst, Queue: l.Queue}
+	for _, bucket := range l.buckets {
+		status.Shippers = append(status.Shippers, shipperStatus{
+			Shipper:   bucket.Shipper,
+			Waiting:   bucket.waiting,
+			Allowed:   bucket.allowed,
+			Delayed:   bucket.delayed,
+			Throttled: bucket.throttled,
+		})
+	}
+
+	sort.Slice(status.Shippers, func(i, j int) bool {
+		return status.Shippers[i].Shipper < status.Shippers[j].Shipper
+	})
+
+	return status
+}
+
+// bucket - bucket of a credential (created full), refilled with the tokens earned since it was last used
+func (l *rateLimiter) bucket(shipper, token string) *tokenBucket {
+	now := l.clock()
+
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+
+	bucket, ok := l.buckets[token]
+	if !ok {
+		bucket = &tokenBucket{Shipper: shipper, tokens: float64(max(l.Burst, 1)), last: now}
+		l.buckets[token] = bucket
+	}
+
+	bucket.tokens = math.Min(bucket.tokens+now.Sub(bucket.last).Seconds()*l.Rate, float64(max(l.Burst, 1)))
+	bucket.last = now
+
+	return bucket
+}
+
+// duration - time to earn an amount of tokens
+func (l *rateLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// clock - current time
+func (l *rateLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+	"github.com/mtrdgs/fr/fakefr"
+)
+
+func TestRateLimiter_Wait(t *testing.T) {
+	tests := []struct {
+		name          string
+		limiter       *rateLimiter
+		calls         int
+		timeout       time.Duration
+		wantThrottled int
+		wantStatus    shipperStatus
+	}{
+		{
+			name:       "test #1 - within burst",
+			limiter:    &rateLimiter{Rate: 1, Burst: 3, Queue: 0},
+			calls:      3,
+			wantStatus: shipperStatus{Shipper: "1", Allowed: 3},
+		},
+		{
+			name:          "test #2 - beyond burst, without queue",
+			limiter:       &rateLimiter{Rate: 1, Burst: 2, Queue: 0},
+			calls:         4,
+			wantThrottled: 2,
+			wantStatus:    shipperStatus{Shipper: "1", Allowed: 2, Throttled: 2},
+		},
+		{
+			name:          "test #3 - queued until queue is full",
+			limiter:       &rateLimiter{Rate: 10, Burst: 1, Queue: 2},
+			calls:         4,
+			wantThrottled: 1,
+			wantStatus:    shipperStatus{Shipper: "1", Allowed: 1, Delayed: 2, Throttled: 1},
+		},
+		{
+			name:          "test #4 - wait outlasts the call",
+			limiter:       &rateLimiter{Rate: 1, Burst: 1, Queue: 10},
+			calls:         2,
+			timeout:       100 * time.Millisecond,
+			wantThrottled: 1,
+			wantStatus:    shipperStatus{Shipper: "1", Allowed: 1, Throttled: 1},
+		},
+		{
+			name:       "test #5 - disabled",
+			limiter:    &rateLimiter{Rate: 0},
+			calls:      10,
+			wantStatus: shipperStatus{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			// concurrent calls, so queued ones wait together
+			errs := make(chan error, tt.calls)
+			for call := 0; call < tt.calls; call++ {
+				go func() {
+					errs <- tt.limiter.Wait(ctx, "1", "token")
+				}()
+				time.Sleep(time.Millisecond) // keep calls' order
+			}
+
+			gotThrottled := 0
+			for call := 0; call < tt.calls; call++ {
+				err := <-errs
+
+				var throttled *throttledError
+				if errors.As(err, &throttled) {
+					gotThrottled++
+					if throttled.RetryAfter <= 0 {
+						t.Errorf("rateLimiter.Wait() retry after = %v, want positive", throttled.RetryAfter)
+					}
+					continue
+				}
+
+				if err != nil {
+					t.Errorf("rateLimiter.Wait() error = %v", err)
+				}
+			}
+
+			if gotThrottled != tt.wantThrottled {
+				t.Errorf("rateLimiter.Wait() throttled = %d, want %d", gotThrottled, tt.wantThrottled)
+			}
+
+			var gotStatus shipperStatus
+			if shippers := tt.limiter.status().Shippers; len(shippers) > 0 {
+				gotStatus = shippers[0]
+			}
+			if gotStatus != tt.wantStatus {
+				t.Errorf("rateLimiter.status() = %+v, want %+v", gotStatus, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_refill(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	limiter := &rateLimiter{Rate: 2, Burst: 2, now: func() time.Time { return now }}
+
+	// each credential has its own bucket
+	for _, token := range []string{"a", "a", "b"} {
+		if err := limiter.Wait(context.Background(), token, token); err != nil {
+			t.Fatalf("rateLimiter.Wait() error = %v", err)
+		}
+	}
+
+	if err := limiter.Wait(context.Background(), "a", "a"); err == nil {
+		t.Errorf("rateLimiter.Wait() allowed a call beyond the burst")
+	}
+
+	// a token every half second
+	now = now.Add(500 * time.Millisecond)
+	if err := limiter.Wait(context.Background(), "a", "a"); err != nil {
+		t.Errorf("rateLimiter.Wait() error = %v after refill", err)
+	}
+}
+
+func TestRateLimiter_cancel(t *testing.T) {
+	limiter := &rateLimiter{Rate: 1, Burst: 1, Queue: 1}
+	_ = limiter.Wait(context.Background(), "1", "token")
+
+	// a call that gives up while queued frees its place
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	if err := limiter.Wait(ctx, "1", "token"); !errors.Is(err, context.Canceled) {
+		t.Errorf("rateLimiter.Wait() error = %v, want %v", err, context.Canceled)
+	}
+
+	if status := limiter.status().Shippers[0]; status.Waiting != 0 {
+		t.Errorf("rateLimiter.status() waiting = %d, want 0", status.Waiting)
+	}
+}
+
+func TestFreteRapidoProvider_postSimulateAPI_limited(t *testing.T) {
+	server := fakefr.NewServer(fakefr.Options{})
+	defer server.Close()
+
+	p := newFakeProvider(server)
+	p.Client = &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Limiter: &rateLimiter{Rate: 0.1, Burst: 1, Queue: 0}}}
+
+	reqAPI := p.buildRequestAPI(validRequestQuote())
+
+	if _, err := p.postSimulateAPI(context.Background(), reqAPI); err != nil {
+		t.Fatalf("freteRapidoProvider.postSimulateAPI() error = %v", err)
+	}
+
+	// throttled without calling the api
+	_, err := p.postSimulateAPI(context.Background(), reqAPI)
+	if status, code := failureStatus(err); status != http.StatusTooManyRequests || code != codeRateLimited {
+		t.Errorf("failureStatus() = %d %s, want %d %s", status, code, http.StatusTooManyRequests, codeRateLimited)
+	}
+
+	if requests := server.Handler.Requests(); len(requests) != 1 {
+		t.Errorf("freteRapidoProvider.postSimulateAPI() sent %d requests, want 1", len(requests))
+	}
+}
+
+func TestConfig_Quote_throttled(t *testing.T) {
+	app := Config{
+		Repo:      data.NewMongoTestRepository(nil),
+		Providers: []QuoteProvider{&stubProvider{name: "freterapido", err: &throttledError{RetryAfter: 2500 * time.Millisecond}}},
+	}
+
+	body, _ := json.Marshal(validRequestQuote())
+
+	req, _ := http.NewRequest(http.MethodPost, "/quote", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler := http.HandlerFunc(app.Quote)
+
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected %d but got %d", http.StatusTooManyRequests, rr.Code)
+	}
+
+	if got := rr.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("expected Retry-After 3 but got %q", got)
+	}
+
+	var got jsonResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &got)
+
+	if got.Code != codeRateLimited {
+		t.Errorf("expected code %s but got %s", codeRateLimited, got.Code)
+	}
+}
+
+func TestRetryTransport_limited(t *testing.T) {
+	tests := []struct {
+		name          string
+		burst         int
+		wantErr       bool
+		wantAttempts  int
+		wantAllowed   int64
+		wantThrottled int64
+	}{
+		{name: "test #1 - retry within the quota", burst: 2, wantErr: false, wantAttempts: 2, wantAllowed: 2},
+		{name: "test #2 - retry beyond the quota", burst: 1, wantErr: true, wantAttempts: 1, wantAllowed: 1, wantThrottled: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &flakyServer{statuses: []int{http.StatusServiceUnavailable}}
+			server := httptest.NewServer(upstream)
+			defer server.Close()
+
+			limiter := &rateLimiter{Rate: 0.1, Burst: tt.burst, Queue: 0}
+			client := &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Retries: 1, Backoff: time.Millisecond, Limiter: limiter}}
+
+			// every attempt takes a token of the credential
+			req, _ := http.NewRequestWithContext(withCredential(context.Background(), "1", "token"), http.MethodGet, server.URL, nil)
+			res, err := client.Do(req)
+			if err == nil {
+				res.Body.Close()
+			}
+
+			var throttled *throttledError
+			if errors.As(err, &throttled) != tt.wantErr {
+				t.Fatalf("retryTransport.RoundTrip() error = %v, want throttled %v", err, tt.wantErr)
+			}
+
+			if len(upstream.bodies) != tt.wantAttempts {
+				t.Errorf("retryTransport.RoundTrip() attempts = %d, want %d", len(upstream.bodies), tt.wantAttempts)
+			}
+
+			if status := limiter.status().Shippers[0]; status.Allowed != tt.wantAllowed || status.Throttled != tt.wantThrottled {
+				t.Errorf("rateLimiter.status() = %+v, want %d allowed and %d throttled", status, tt.wantAllowed, tt.wantThrottled)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_limitedOpen(t *testing.T) {
+	upstream := &flakyServer{}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+
+	breaker := &circuitBreaker{Failures: 1, Cooldown: time.Minute, state: breakerOpen, failures: 1, openedAt: time.Now()}
+	limiter := &rateLimiter{Rate: 0.1, Burst: 1, Queue: 1}
+	client := &http.Client{Transport: &retryTransport{Next: http.DefaultTransport, Breaker: breaker, Limiter: limiter}}
+
+	// fails right away, without taking a token of the credential
+	for range 2 {
+		req, _ := http.NewRequestWithContext(withCredential(context.Background(), "1", "token"), http.MethodGet, server.URL, nil)
+
+		var circuitOpen *circuitOpenError
+		if _, err := client.Do(req); !errors.As(err, &circuitOpen) {
+			t.Fatalf("retryTransport.RoundTrip() error = %v, want circuit open", err)
+		}
+	}
+
+	if shippers := limiter.status().Shippers; len(shippers) != 0 {
+		t.Errorf("rateLimiter.status() = %+v, want no calls charged", shippers)
+	}
+
+	if len(upstream.bodies) != 0 {
+		t.Errorf("retryTransport.RoundTrip() attempts = %d, want 0", len(upstream.bodies))
+	}
+}
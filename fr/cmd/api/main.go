@@ -12,22 +12,27 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	defaultPort = "80"
-	mongoURL    = "mongodb://mongo:27017"
-	apiURL      = "https://sp.freterapido.com/api/v3/quote/simulate"
-)
-
 var client *mongo.Client
 
 type Config struct {
-	Repo   data.RepositoryPattern
-	Client *http.Client
+	Repo      data.RepositoryPattern
+	Client    *http.Client
+	Breaker   *circuitBreaker // of the providers' client
+	Limiter   *rateLimiter    // quota of calls to providers
+	Providers []QuoteProvider
+	Cache     QuoteCache
+	Settings  settings
 }
 
 func main() {
+	// load settings (fail fast when something required is missing)
+	settings, err := loadSettings()
+	if err != nil {
+		log.Fatalf("Error loading settings: %v", err)
+	}
+
 	// connect to mongo
-	mongoClient, err := connectToMongo()
+	mongoClient, err := connectToMongo(settings.Mongo)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -45,16 +50,33 @@ func main() {
 	}()
 
 	app := Config{
-		Client: &http.Client{},
+		Settings: settings,
 		//Models: data.New(client),
 	}
 
+	// client used by providers (timeouts, retries, circuit breaker and quota of calls)
+	app.Limiter = newRateLimiter(settings.RateLimit)
+	app.Client, app.Breaker = newClient(settings.Client, app.Limiter)
+
 	app.setUpRepo(client)
 
-	log.Printf("Starting server on port %s.", defaultPort)
+	// cache providers' responses (before providers, which use it)
+	app.setUpCache(settings.Cache)
+
+	// enable quote providers
+	err = app.setUpProviders(settings.Providers)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !settings.Auth.Enabled {
+		log.Println("Authentication is disabled, every route is open!")
+	}
+
+	log.Printf("Starting server on port %s.", settings.Port)
 
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%s", defaultPort),
+		Addr:    fmt.Sprintf(":%s", settings.Port),
 		Handler: app.routes(),
 	}
 
@@ -65,13 +87,15 @@ func main() {
 }
 
 // connectToMongo - connects to mongo and returns a client and potential error
-func connectToMongo() (*mongo.Client, error) {
+func connectToMongo(settings mongoSettings) (*mongo.Client, error) {
 	// create client connection options
-	clientOptions := options.Client().ApplyURI(mongoURL)
-	clientOptions.SetAuth(options.Credential{
-		Username: "admin",
-		Password: "password",
-	})
+	clientOptions := options.Client().ApplyURI(settings.URL)
+	if settings.Username != "" {
+		clientOptions.SetAuth(options.Credential{
+			Username: settings.Username,
+			Password: settings.Password,
+		})
+	}
 
 	// connect
 	c, err := mongo.Connect(context.TODO(), clientOptions)
@@ -87,5 +111,42 @@ func connectToMongo() (*mongo.Client, error) {
 // setUpRepo - sets up the repository to be used in the app
 func (app *Config) setUpRepo(conn *mongo.Client) {
 	mongo := data.NewMongoRepository(conn)
+
+	// prices stored before money was kept in cents
+	migrated, err := mongo.MigrateMoney()
+	if err != nil {
+		log.Panic(err)
+	}
+	if migrated > 0 {
+		log.Printf("Migrated prices of %d quotes to cents.", migrated)
+	}
+
+	// expired cache entries are removed by mongo
+	err = mongo.EnsureCacheIndexes()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// api keys are found by their hash
+	err = mongo.EnsureAPIKeyIndexes()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// tenants are found by their registered number (and quotes by their tenant)
+	err = mongo.EnsureTenantIndexes()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// quotes stored before tenants belong to the configured shipper
+	migrated, err = mongo.MigrateShipper(app.Settings.FreteRapido.RegisteredNumber)
+	if err != nil {
+		log.Panic(err)
+	}
+	if migrated > 0 {
+		log.Printf("Assigned %d quotes to shipper %s.", migrated, app.Settings.FreteRapido.RegisteredNumber)
+	}
+
 	app.Repo = mongo
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// buckets - accepted values for the bucket option of metrics
+var buckets = map[string]bool{
+	"":      true, // a single metric, for every quote
+	"day":   true,
+	"week":  true,
+	"month": true,
+}
+
+// groupings - accepted values for the group_by option of metrics (key of every map in a metric)
+var groupings = map[string]bool{
+	"carrier":           true,
+	"carrier_service":   true, // ex.: "CORREIOS - Rodoviário"
+	"service":           true,
+	"destination_state": true, // ex.: "SP"
+}
+
+// ResponseMetrics -
+type responseMetrics struct {
+	GroupBy string   `json:"group_by"`
+	Metrics []metric `json:"metrics"`
+}
+
+// Metric -
+type metric struct {
+	PeriodStart          *time.Time            `json:"period_start,omitempty"` // inclusive (only when bucketed)
+	PeriodEnd            *time.Time            `json:"period_end,omitempty"`   // exclusive (only when bucketed)
+	ResultsPerCarrier    map[string]int        `json:"results_per_carrier"`
+	TotalPricePerCarrier map[string]data.Money `json:"total_price_per_carrier"`
+	AvgPricePerCarrier   map[string]data.Money `json:"avg_price_per_carrier"`
+	CheapestFreight      map[string]data.Money `json:"cheapest_freight"`
+	PriciestFreight      map[string]data.Money `json:"priciest_freight"`
+
+	// dispersion of prices
+	MedianPricePerCarrier map[string]data.Money `json:"median_price_per_carrier"`
+	P90PricePerCarrier    map[string]data.Money `json:"p90_price_per_carrier"`
+	P95PricePerCarrier    map[string]data.Money `json:"p95_price_per_carrier"`
+	StdDevPricePerCarrier map[string]data.Money `json:"stddev_price_per_carrier"`
+
+	// deadlines (days)
+	AvgDeadlinePerCarrier map[string]float64    `json:"avg_deadline_per_carrier"`
+	FastestDeadline       map[string]int        `json:"fastest_deadline"`
+	SlowestDeadline       map[string]int        `json:"slowest_deadline"`
+	PricePerDeliveryDay   map[string]data.Money `json:"price_per_delivery_day"` // avg price / avg deadline (at least 1 day)
+
+	// dispersion of deadlines (days)
+	MedianDeadlinePerCarrier map[string]float64 `json:"median_deadline_per_carrier"`
+	P90DeadlinePerCarrier    map[string]float64 `json:"p90_deadline_per_carrier"`
+	P95DeadlinePerCarrier    map[string]float64 `json:"p95_deadline_per_carrier"`
+	StdDevDeadlinePerCarrier map[string]float64 `json:"stddev_deadline_per_carrier"`
+
+	// price and deadline combined (best first)
+	BestValue []bestValue `json:"best_value"`
+
+	// offers left out by the metrics policy (not in any map above)
+	ExcludedOffers           map[string]int `json:"excluded_offers"`             // per reason (zero_price or invalid)
+	ExcludedOffersPerCarrier map[string]int `json:"excluded_offers_per_carrier"` // per group
+}
+
+// BestValue - position of a carrier in the best value ranking
+type bestValue struct {
+	Name        string     `json:"name"`
+	Score       float64    `json:"score"` // 0 (cheapest and fastest) to 1 (priciest and slowest)
+	AvgPrice    data.Money `json:"avg_price"`
+	AvgDeadline float64    `json:"avg_deadline"`
+}
+
+// newMetric - creates an empty metric (maps are always present in json)
+func newMetric() metric {
+	return metric{
+		ResultsPerCarrier:    make(map[string]int),
+		TotalPricePerCarrier: make(map[string]data.Money),
+		AvgPricePerCarrier:   make(map[string]data.Money),
+		CheapestFreight:      make(map[string]data.Money),
+		PriciestFreight:      make(map[string]data.Money),
+
+		MedianPricePerCarrier: make(map[string]data.Money),
+		P90PricePerCarrier:    make(map[string]data.Money),
+		P95PricePerCarrier:    make(map[string]data.Money),
+		StdDevPricePerCarrier: make(map[string]data.Money),
+
+		AvgDeadlinePerCarrier: make(map[string]float64),
+		FastestDeadline:       make(map[string]int),
+		SlowestDeadline:       make(map[string]int),
+		PricePerDeliveryDay:   make(map[string]data.Money),
+
+		MedianDeadlinePerCarrier: make(map[string]float64),
+		P90DeadlinePerCarrier:    make(map[string]float64),
+		P95DeadlinePerCarrier:    make(map[string]float64),
+		StdDevDeadlinePerCarrier: make(map[string]float64),
+
+		BestValue: make([]bestValue, 0),
+
+		ExcludedOffers:           make(map[string]int),
+		ExcludedOffersPerCarrier: make(map[string]int),
+	}
+}
+
+// prepareMetricsResponse - formats the stats aggregated from quotes (stored in the db) as readable json to send to client
+// when bucket is set (day, week or month), there is one metric per period, ordered by its start
+// priceWeight (0 to 1) is how much price matters in the best value ranking (the rest is deadline)
+func (app *Config) prepareMetricsResponse(stats []data.CarrierStats, query data.MetricsQuery, priceWeight float64) responseMetrics {
+	bucket := query.Bucket
+	response := responseMetrics{GroupBy: query.GroupBy, Metrics: make([]metric, 0)}
+
+	// a single metric (even without quotes)
+	if bucket == "" {
+		response.Metrics = append(response.Metrics, newMetric())
+	}
+
+	// stats are sorted by period, so a new period means a new metric
+	for _, carrierStats := range stats {
+		// offers of quotes without creation date are in no period
+		if bucket != "" && carrierStats.PeriodStart == nil {
+			continue
+		}
+
+		current := len(response.Metrics) - 1
+		if bucket != "" && (current < 0 || !response.Metrics[current].PeriodStart.Equal(*carrierStats.PeriodStart)) {
+			start := *carrierStats.PeriodStart
+			end := data.BucketEnd(start, bucket)
+
+			mapMetrics := newMetric()
+			mapMetrics.PeriodStart = &start
+			mapMetrics.PeriodEnd = &end
+
+			response.Metrics = append(response.Metrics, mapMetrics)
+			current++
+		}
+
+		mapMetrics := response.Metrics[current]
+		name := carrierStats.Key
+
+		// excluded offers
+		excluded := map[string]int{data.ExcludedZeroPrice: carrierStats.ExcludedZeroPrice, data.ExcludedInvalid: carrierStats.ExcludedInvalid}
+		for reason, amount := range excluded {
+			if amount > 0 {
+				mapMetrics.ExcludedOffers[reason] += amount
+				mapMetrics.ExcludedOffersPerCarrier[name] += amount
+			}
+		}
+
+		// every offer of the group was excluded (no price to show)
+		if carrierStats.Count == 0 {
+			continue
+		}
+
+		// total results
+		mapMetrics.ResultsPerCarrier[name] = carrierStats.Count
+
+		// total price (exact, as prices are in cents)
+		mapMetrics.TotalPricePerCarrier[name] = carrierStats.Total
+
+		// avg price (rounded to cents only here)
+		mapMetrics.AvgPricePerCarrier[name] = data.RoundMoney(carrierStats.Average)
+
+		// cheapest and priciest freight
+		mapMetrics.CheapestFreight[name] = carrierStats.Min
+		mapMetrics.PriciestFreight[name] = carrierStats.Max
+
+		// price dispersion (volatile carriers have a high std dev / p95 far from median)
+		mapMetrics.MedianPricePerCarrier[name] = data.RoundMoney(carrierStats.Median)
+		mapMetrics.P90PricePerCarrier[name] = data.RoundMoney(carrierStats.P90)
+		mapMetrics.P95PricePerCarrier[name] = data.RoundMoney(carrierStats.P95)
+		mapMetrics.StdDevPricePerCarrier[name] = data.RoundMoney(carrierStats.StdDev)
+
+		// deadlines
+		mapMetrics.AvgDeadlinePerCarrier[name] = round(carrierStats.DeadlineAverage)
+		mapMetrics.FastestDeadline[name] = carrierStats.DeadlineMin
+		mapMetrics.SlowestDeadline[name] = carrierStats.DeadlineMax
+		mapMetrics.PricePerDeliveryDay[name] = data.RoundMoney(carrierStats.Average / max(carrierStats.DeadlineAverage, 1))
+
+		// deadline dispersion
+		mapMetrics.MedianDeadlinePerCarrier[name] = round(carrierStats.DeadlineMedian)
+		mapMetrics.P90DeadlinePerCarrier[name] = round(carrierStats.DeadlineP90)
+		mapMetrics.P95DeadlinePerCarrier[name] = round(carrierStats.DeadlineP95)
+		mapMetrics.StdDevDeadlinePerCarrier[name] = round(carrierStats.DeadlineStdDev)
+	}
+
+	// rank carriers of every metric
+	for key := range response.Metrics {
+		response.Metrics[key].rankBestValue(priceWeight)
+	}
+
+	return response
+}
+
+// rankBestValue - ranks carriers by a score that combines avg price and avg deadline,
+// both normalized between the best (0) and the worst (1) carrier of the metric
+func (m *metric) rankBestValue(priceWeight float64) {
+	m.BestValue = make([]bestValue, 0, len(m.AvgPricePerCarrier))
+	if len(m.AvgPricePerCarrier) == 0 {
+		return
+	}
+
+	// ranges of avg price and avg deadline
+	minPrice, maxPrice := math.Inf(1), math.Inf(-1)
+	minDeadline, maxDeadline := math.Inf(1), math.Inf(-1)
+	for name, avgPrice := range m.AvgPricePerCarrier {
+		price, deadline := float64(avgPrice), m.AvgDeadlinePerCarrier[name]
+
+		minPrice, maxPrice = min(minPrice, price), max(maxPrice, price)
+		minDeadline, maxDeadline = min(minDeadline, deadline), max(maxDeadline, deadline)
+	}
+
+	for name, avgPrice := range m.AvgPricePerCarrier {
+		price, deadline := float64(avgPrice), m.AvgDeadlinePerCarrier[name]
+		score := priceWeight*normalize(price, minPrice, maxPrice) + (1-priceWeight)*normalize(deadline, minDeadline, maxDeadline)
+
+		m.BestValue = append(m.BestValue, bestValue{
+			Name:        name,
+			Score:       math.Round(score*10000) / 10000,
+			AvgPrice:    avgPrice,
+			AvgDeadline: deadline,
+		})
+	}
+
+	// best first (ties by name, so the order is stable)
+	sort.Slice(m.BestValue, func(i, j int) bool {
+		if m.BestValue[i].Score != m.BestValue[j].Score {
+			return m.BestValue[i].Score < m.BestValue[j].Score
+		}
+
+		return m.BestValue[i].Name < m.BestValue[j].Name
+	})
+}
+
+// normalize - position of a value between min (0) and max (1)
+func normalize(value, lower, upper float64) float64 {
+	if upper == lower {
+		return 0
+	}
+
+	return (value - lower) / (upper - lower)
+}
+
+// round - rounds to 2 decimal places (half away from zero)
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
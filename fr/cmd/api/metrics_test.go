@@ -0,0 +1,447 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// quoteAt - stored quote created at a given date, with a single offer
+func quoteAt(date string, name string, price data.Money) data.QuoteEntry {
+	createdAt, _ := time.Parse(time.RFC3339, date)
+
+	return data.QuoteEntry{
+		Carrier:   []data.Carrier{{Name: name, Price: price}},
+		CreatedAt: &createdAt,
+	}
+}
+
+func TestConfig_prepareMetricsResponse(t *testing.T) {
+	quotes := []data.QuoteEntry{
+		quoteAt("2025-01-06T10:00:00Z", "CORREIOS", 1000), // monday
+		quoteAt("2025-01-06T23:00:00Z", "CORREIOS", 2000),
+		quoteAt("2025-01-12T08:00:00Z", "CORREIOS", 3000), // sunday
+		quoteAt("2025-02-03T08:00:00Z", "CORREIOS", 4000),
+	}
+
+	tests := []struct {
+		name        string
+		bucket      string
+		wantStarts  []string
+		wantEnds    []string
+		wantResults []int
+	}{
+		{name: "test #1 - no bucket", bucket: "", wantStarts: []string{""}, wantEnds: []string{""}, wantResults: []int{4}},
+		{
+			name:        "test #2 - per day",
+			bucket:      "day",
+			wantStarts:  []string{"2025-01-06", "2025-01-12", "2025-02-03"},
+			wantEnds:    []string{"2025-01-07", "2025-01-13", "2025-02-04"},
+			wantResults: []int{2, 1, 1},
+		},
+		{
+			name:        "test #3 - per week",
+			bucket:      "week",
+			wantStarts:  []string{"2025-01-06", "2025-02-03"},
+			wantEnds:    []string{"2025-01-13", "2025-02-10"},
+			wantResults: []int{3, 1},
+		},
+		{
+			name:        "test #4 - per month",
+			bucket:      "month",
+			wantStarts:  []string{"2025-01-01", "2025-02-01"},
+			wantEnds:    []string{"2025-02-01", "2025-03-01"},
+			wantResults: []int{3, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// aggregate quotes with the mocked repository (same results as mongo's)
+			repo := data.NewMongoTestRepository(nil)
+			for _, quote := range quotes {
+				_, _ = repo.Insert(quote)
+			}
+
+			app := &Config{Repo: repo}
+
+			// only the quotes above (skips the default mocked one)
+			from, _ := parseTimeParam("2025-01-01", false)
+			to, _ := parseTimeParam("2025-12-31", true)
+			query := data.MetricsQuery{From: from, To: to, Bucket: tt.bucket}
+			stats, _ := app.Repo.AggregateMetrics(query)
+
+			got := app.prepareMetricsResponse(stats, query, defaultPriceWeight)
+
+			if len(got.Metrics) != len(tt.wantStarts) {
+				t.Fatalf("Config.prepareMetricsResponse() metrics = %d, want %d", len(got.Metrics), len(tt.wantStarts))
+			}
+
+			for key, metric := range got.Metrics {
+				gotStart, gotEnd := "", ""
+				if metric.PeriodStart != nil {
+					gotStart = metric.PeriodStart.Format(time.DateOnly)
+					gotEnd = metric.PeriodEnd.Format(time.DateOnly)
+				}
+
+				if gotStart != tt.wantStarts[key] || gotEnd != tt.wantEnds[key] {
+					t.Errorf("metric[%d] period = %s - %s, want %s - %s", key, gotStart, gotEnd, tt.wantStarts[key], tt.wantEnds[key])
+				}
+
+				if metric.ResultsPerCarrier["CORREIOS"] != tt.wantResults[key] {
+					t.Errorf("metric[%d] results = %d, want %d", key, metric.ResultsPerCarrier["CORREIOS"], tt.wantResults[key])
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_prepareMetricsResponse_withoutCreation(t *testing.T) {
+	start := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	// stats of quotes without creation date have no period
+	stats := []data.CarrierStats{
+		{Key: "CORREIOS", Count: 1, Total: 10050, Min: 10050, Max: 10050},
+		{PeriodStart: &start, Key: "CORREIOS", Count: 2, Total: 20100, Min: 10050, Max: 10050},
+	}
+
+	got := (&Config{}).prepareMetricsResponse(stats, data.MetricsQuery{Bucket: "day"}, defaultPriceWeight)
+
+	if len(got.Metrics) != 1 || !got.Metrics[0].PeriodStart.Equal(start) || got.Metrics[0].ResultsPerCarrier["CORREIOS"] != 2 {
+		t.Errorf("Config.prepareMetricsResponse() = %+v, want only the metric of %s", got.Metrics, start)
+	}
+}
+
+func TestConfig_prepareMetricsResponse_stats(t *testing.T) {
+	quotes := []data.QuoteEntry{
+		{Carrier: []data.Carrier{{Name: "CORREIOS", Price: 10050}, {Name: "AZUL CARGO", Price: 4182}, {Name: "JADLOG", Price: 9335}}},
+		{Carrier: []data.Carrier{{Name: "CORREIOS", Price: 15025}, {Name: "BOX DELIVERY", Price: 0}, {Name: "JADLOG", Price: 9335}}},
+		{Carrier: []data.Carrier{{Name: "CORREIOS", Price: 11925}, {Name: "BOX DELIVERY", Price: 1000}, {Name: "JADLOG", Price: 9335}}},
+	}
+
+	repo := data.NewMongoTestRepository(nil)
+	for _, quote := range quotes {
+		_, _ = repo.Insert(quote)
+	}
+
+	app := &Config{Repo: repo}
+
+	// last 3 quotes (skips the default mocked one)
+	query := data.MetricsQuery{Amount: 3}
+	stats, _ := app.Repo.AggregateMetrics(query)
+	got := app.prepareMetricsResponse(stats, query, defaultPriceWeight).Metrics[0]
+
+	// results, total, avg, cheapest, priciest (cents)
+	want := map[string][5]data.Money{
+		"CORREIOS":     {3, 37000, 12333, 10050, 15025},
+		"AZUL CARGO":   {1, 4182, 4182, 4182, 4182},
+		"BOX DELIVERY": {2, 1000, 500, 0, 1000},
+		"JADLOG":       {3, 28005, 9335, 9335, 9335}, // no drift (93.34 when averaged as float and truncated)
+	}
+
+	for name, values := range want {
+		gotValues := [5]data.Money{
+			data.Money(got.ResultsPerCarrier[name]),
+			got.TotalPricePerCarrier[name],
+			got.AvgPricePerCarrier[name],
+			got.CheapestFreight[name],
+			got.PriciestFreight[name],
+		}
+
+		if gotValues != values {
+			t.Errorf("metric for %s = %v, want %v", name, gotValues, values)
+		}
+	}
+}
+
+func TestConfig_prepareMetricsResponse_dispersion(t *testing.T) {
+	repo := data.NewMongoTestRepository(nil)
+	for key, price := range []data.Money{1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000, 10000} {
+		_, _ = repo.Insert(data.QuoteEntry{Carrier: []data.Carrier{{Name: "CORREIOS", Price: price, Deadline: key + 1}}})
+	}
+
+	app := &Config{Repo: repo}
+
+	query := data.MetricsQuery{Amount: 10}
+	stats, _ := app.Repo.AggregateMetrics(query)
+	got := app.prepareMetricsResponse(stats, query, defaultPriceWeight).Metrics[0]
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "median price", got: got.MedianPricePerCarrier["CORREIOS"].Float64(), want: 55},
+		{name: "p90 price", got: got.P90PricePerCarrier["CORREIOS"].Float64(), want: 91},
+		{name: "p95 price", got: got.P95PricePerCarrier["CORREIOS"].Float64(), want: 95.5},
+		{name: "std dev price", got: got.StdDevPricePerCarrier["CORREIOS"].Float64(), want: 28.72},
+		{name: "median deadline", got: got.MedianDeadlinePerCarrier["CORREIOS"], want: 5.5},
+		{name: "p90 deadline", got: got.P90DeadlinePerCarrier["CORREIOS"], want: 9.1},
+		{name: "p95 deadline", got: got.P95DeadlinePerCarrier["CORREIOS"], want: 9.55},
+		{name: "std dev deadline", got: got.StdDevDeadlinePerCarrier["CORREIOS"], want: 2.87},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_prepareMetricsResponse_approximation(t *testing.T) {
+	// 1000 prices from 100.00 to 199.90, which share histogram bins (1% wide)
+	repo := data.NewMongoTestRepository(nil)
+	for price := data.Money(10000); price < 20000; price += 10 {
+		_, _ = repo.Insert(data.QuoteEntry{Carrier: []data.Carrier{{Name: "CORREIOS", Price: price, Deadline: 3}}})
+	}
+
+	app := &Config{Repo: repo}
+
+	query := data.MetricsQuery{Amount: 1000}
+	stats, _ := app.Repo.AggregateMetrics(query)
+	got := app.prepareMetricsResponse(stats, query, defaultPriceWeight).Metrics[0]
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "median price", got: got.MedianPricePerCarrier["CORREIOS"].Float64(), want: 149.95},
+		{name: "p90 price", got: got.P90PricePerCarrier["CORREIOS"].Float64(), want: 189.91},
+		{name: "p95 price", got: got.P95PricePerCarrier["CORREIOS"].Float64(), want: 194.905},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// within the width of a bin
+			if math.Abs(tt.got-tt.want) > tt.want*0.01 {
+				t.Errorf("%s = %v, want %v (1%%)", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+
+	// small values have bins of their own (exact)
+	if got.MedianDeadlinePerCarrier["CORREIOS"] != 3 {
+		t.Errorf("median deadline = %v, want 3", got.MedianDeadlinePerCarrier["CORREIOS"])
+	}
+}
+
+func TestConfig_prepareMetricsResponse_groupBy(t *testing.T) {
+	quotes := []data.QuoteEntry{
+		{
+			Carrier: []data.Carrier{{Name: "CORREIOS", Service: "Rodoviário", Price: 1000}, {Name: "CORREIOS", Service: "Aéreo", Price: 3000}},
+			Request: &data.QuoteRequest{Destination: data.Destination{Zipcode: "01311000", State: "SP"}},
+		},
+		{
+			Carrier: []data.Carrier{{Name: "AZUL CARGO", Service: "Aéreo", Price: 4000}},
+			Request: &data.QuoteRequest{Destination: data.Destination{Zipcode: "88010000", State: "SC"}},
+		},
+		{
+			Carrier: []data.Carrier{{Name: "CORREIOS", Service: "Rodoviário", Price: 2000}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		groupBy     string
+		wantResults map[string]int
+		wantAvg     map[string]data.Money
+	}{
+		{
+			name:        "test #1 - carrier",
+			groupBy:     "carrier",
+			wantResults: map[string]int{"CORREIOS": 3, "AZUL CARGO": 1},
+			wantAvg:     map[string]data.Money{"CORREIOS": 2000, "AZUL CARGO": 4000},
+		},
+		{
+			name:        "test #2 - carrier and service",
+			groupBy:     "carrier_service",
+			wantResults: map[string]int{"CORREIOS - Rodoviário": 2, "CORREIOS - Aéreo": 1, "AZUL CARGO - Aéreo": 1},
+			wantAvg:     map[string]data.Money{"CORREIOS - Rodoviário": 1500, "CORREIOS - Aéreo": 3000, "AZUL CARGO - Aéreo": 4000},
+		},
+		{
+			name:        "test #3 - service",
+			groupBy:     "service",
+			wantResults: map[string]int{"Rodoviário": 2, "Aéreo": 2},
+			wantAvg:     map[string]data.Money{"Rodoviário": 1500, "Aéreo": 3500},
+		},
+		{
+			name:        "test #4 - destination state",
+			groupBy:     "destination_state",
+			wantResults: map[string]int{"SP": 2, "SC": 1, "unknown": 1},
+			wantAvg:     map[string]data.Money{"SP": 2000, "SC": 4000, "unknown": 2000},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := data.NewMongoTestRepository(nil)
+			for _, quote := range quotes {
+				_, _ = repo.Insert(quote)
+			}
+
+			app := &Config{Repo: repo}
+
+			query := data.MetricsQuery{Amount: int64(len(quotes)), GroupBy: tt.groupBy}
+			stats, _ := app.Repo.AggregateMetrics(query)
+			got := app.prepareMetricsResponse(stats, query, defaultPriceWeight)
+
+			if got.GroupBy != tt.groupBy {
+				t.Errorf("group_by = %s, want %s", got.GroupBy, tt.groupBy)
+			}
+
+			if !reflect.DeepEqual(got.Metrics[0].ResultsPerCarrier, tt.wantResults) {
+				t.Errorf("results = %v, want %v", got.Metrics[0].ResultsPerCarrier, tt.wantResults)
+			}
+
+			if !reflect.DeepEqual(got.Metrics[0].AvgPricePerCarrier, tt.wantAvg) {
+				t.Errorf("avg price = %v, want %v", got.Metrics[0].AvgPricePerCarrier, tt.wantAvg)
+			}
+		})
+	}
+}
+
+func TestConfig_prepareMetricsResponse_deadlines(t *testing.T) {
+	quotes := []data.QuoteEntry{
+		{Carrier: []data.Carrier{
+			{Name: "CORREIOS", Price: 10000, Deadline: 4},
+			{Name: "SEDEX", Price: 20000, Deadline: 1},
+			{Name: "BOX DELIVERY", Price: 2000, Deadline: 0},
+		}},
+		{Carrier: []data.Carrier{
+			{Name: "CORREIOS", Price: 12000, Deadline: 6},
+			{Name: "SEDEX", Price: 22000, Deadline: 1},
+			{Name: "BOX DELIVERY", Price: 4000, Deadline: 10},
+		}},
+	}
+
+	repo := data.NewMongoTestRepository(nil)
+	for _, quote := range quotes {
+		_, _ = repo.Insert(quote)
+	}
+
+	app := &Config{Repo: repo}
+
+	query := data.MetricsQuery{Amount: 2}
+	stats, _ := app.Repo.AggregateMetrics(query)
+
+	got := app.prepareMetricsResponse(stats, query, defaultPriceWeight).Metrics[0]
+
+	wantAvg := map[string]float64{"CORREIOS": 5, "SEDEX": 1, "BOX DELIVERY": 5}
+	if !reflect.DeepEqual(got.AvgDeadlinePerCarrier, wantAvg) {
+		t.Errorf("avg deadline = %v, want %v", got.AvgDeadlinePerCarrier, wantAvg)
+	}
+
+	wantFastest := map[string]int{"CORREIOS": 4, "SEDEX": 1, "BOX DELIVERY": 0}
+	if !reflect.DeepEqual(got.FastestDeadline, wantFastest) {
+		t.Errorf("fastest deadline = %v, want %v", got.FastestDeadline, wantFastest)
+	}
+
+	wantSlowest := map[string]int{"CORREIOS": 6, "SEDEX": 1, "BOX DELIVERY": 10}
+	if !reflect.DeepEqual(got.SlowestDeadline, wantSlowest) {
+		t.Errorf("slowest deadline = %v, want %v", got.SlowestDeadline, wantSlowest)
+	}
+
+	wantPerDay := map[string]data.Money{"CORREIOS": 2200, "SEDEX": 21000, "BOX DELIVERY": 600}
+	if !reflect.DeepEqual(got.PricePerDeliveryDay, wantPerDay) {
+		t.Errorf("price per delivery day = %v, want %v", got.PricePerDeliveryDay, wantPerDay)
+	}
+
+	// price: BOX 30 (0), CORREIOS 110 (0.4211), SEDEX 210 (1) / deadline: SEDEX 1 (0), others 5 (1)
+	tests := []struct {
+		name        string
+		priceWeight float64
+		wantRanking []string
+	}{
+		{name: "test #1 - balanced (tie by name)", priceWeight: 0.5, wantRanking: []string{"BOX DELIVERY", "SEDEX", "CORREIOS"}},
+		{name: "test #2 - deadline matters more", priceWeight: 0.4, wantRanking: []string{"SEDEX", "BOX DELIVERY", "CORREIOS"}},
+		{name: "test #3 - price only", priceWeight: 1, wantRanking: []string{"BOX DELIVERY", "CORREIOS", "SEDEX"}},
+		{name: "test #4 - deadline only", priceWeight: 0, wantRanking: []string{"SEDEX", "BOX DELIVERY", "CORREIOS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranking := app.prepareMetricsResponse(stats, query, tt.priceWeight).Metrics[0].BestValue
+
+			gotRanking := make([]string, 0, len(ranking))
+			for _, value := range ranking {
+				gotRanking = append(gotRanking, value.Name)
+			}
+
+			if !reflect.DeepEqual(gotRanking, tt.wantRanking) {
+				t.Errorf("best value ranking = %v, want %v", gotRanking, tt.wantRanking)
+			}
+		})
+	}
+}
+
+func TestConfig_prepareMetricsResponse_excluded(t *testing.T) {
+	quotes := []data.QuoteEntry{
+		{Carrier: []data.Carrier{{Name: "BOX DELIVERY", Price: 0}, {Name: "CORREIOS", Price: 500}, {Name: "", Price: 100}}},
+		{Carrier: []data.Carrier{{Name: "BOX DELIVERY", Price: 1000}, {Name: "FREE", Price: 0}, {Name: "BROKEN", Price: -5}}},
+	}
+
+	repo := data.NewMongoTestRepository(nil)
+	for _, quote := range quotes {
+		_, _ = repo.Insert(quote)
+	}
+
+	app := &Config{Repo: repo}
+
+	tests := []struct {
+		name                 string
+		policy               data.OfferPolicy
+		wantCheapest         map[string]data.Money
+		wantPriciest         map[string]data.Money
+		wantExcluded         map[string]int
+		wantExcludedPerGroup map[string]int
+	}{
+		{
+			name:                 "test #1 - every offer (free offers are a valid min)",
+			policy:               data.OfferPolicy{},
+			wantCheapest:         map[string]data.Money{"BOX DELIVERY": 0, "CORREIOS": 500, "": 100, "FREE": 0, "BROKEN": -5},
+			wantPriciest:         map[string]data.Money{"BOX DELIVERY": 1000, "CORREIOS": 500, "": 100, "FREE": 0, "BROKEN": -5},
+			wantExcluded:         map[string]int{},
+			wantExcludedPerGroup: map[string]int{},
+		},
+		{
+			name:                 "test #2 - without zero priced offers",
+			policy:               data.OfferPolicy{ExcludeZeroPrice: true},
+			wantCheapest:         map[string]data.Money{"BOX DELIVERY": 1000, "CORREIOS": 500, "": 100, "BROKEN": -5},
+			wantPriciest:         map[string]data.Money{"BOX DELIVERY": 1000, "CORREIOS": 500, "": 100, "BROKEN": -5},
+			wantExcluded:         map[string]int{"zero_price": 2},
+			wantExcludedPerGroup: map[string]int{"BOX DELIVERY": 1, "FREE": 1},
+		},
+		{
+			name:                 "test #3 - without zero priced and invalid offers",
+			policy:               data.OfferPolicy{ExcludeZeroPrice: true, ExcludeInvalid: true},
+			wantCheapest:         map[string]data.Money{"BOX DELIVERY": 1000, "CORREIOS": 500},
+			wantPriciest:         map[string]data.Money{"BOX DELIVERY": 1000, "CORREIOS": 500},
+			wantExcluded:         map[string]int{"zero_price": 2, "invalid": 2},
+			wantExcludedPerGroup: map[string]int{"BOX DELIVERY": 1, "FREE": 1, "": 1, "BROKEN": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := data.MetricsQuery{Amount: 2, Policy: tt.policy}
+			stats, _ := app.Repo.AggregateMetrics(query)
+			got := app.prepareMetricsResponse(stats, query, defaultPriceWeight).Metrics[0]
+
+			if !reflect.DeepEqual(got.CheapestFreight, tt.wantCheapest) {
+				t.Errorf("cheapest freight = %v, want %v", got.CheapestFreight, tt.wantCheapest)
+			}
+
+			if !reflect.DeepEqual(got.PriciestFreight, tt.wantPriciest) {
+				t.Errorf("priciest freight = %v, want %v", got.PriciestFreight, tt.wantPriciest)
+			}
+
+			if !reflect.DeepEqual(got.ExcludedOffers, tt.wantExcluded) {
+				t.Errorf("excluded offers = %v, want %v", got.ExcludedOffers, tt.wantExcluded)
+			}
+
+			if !reflect.DeepEqual(got.ExcludedOffersPerCarrier, tt.wantExcludedPerGroup) {
+				t.Errorf("excluded offers per carrier = %v, want %v", got.ExcludedOffersPerCarrier, tt.wantExcludedPerGroup)
+			}
+		})
+	}
+}
@@ -1,11 +1,84 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// ResponseQuote - merged offers from every provider, plus the errors of the ones that failed
+type responseQuote struct {
+	data.QuoteEntry
+	Summary *quoteSummary              `json:"summary,omitempty"`
+	Origins []originQuote              `json:"origins,omitempty"`
+	Errors  map[string]providerFailure `json:"errors,omitempty"` // why each failed provider failed
+}
+
+// OriginQuote - offers from a single origin (warehouse)
+type originQuote struct {
+	Zipcode string         `json:"zipcode"`
+	Carrier []data.Carrier `json:"carrier"`
+}
+
+// ResponseQuotes - a page of stored quotes
+type responseQuotes struct {
+	Quotes     []data.QuoteEntry `json:"quotes"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// ResponseValidation - whether the offers of a stored quote are still honored
+type responseValidation struct {
+	ID        string            `json:"id"`
+	Valid     bool              `json:"valid"` // every offer is still valid
+	CheckedAt time.Time         `json:"checked_at"`
+	Offers    []offerValidation `json:"offers"`
+}
+
+// OfferValidation - a stored offer and its status
+type offerValidation struct {
+	data.Carrier
+	Status string `json:"status"` // valid, expired or unknown (stored without expiration)
+}
+
+// ResponseStatus - state of the app's dependencies
+type responseStatus struct {
+	Upstream  breakerStatus `json:"upstream"`   // circuit breaker of the providers' client
+	RateLimit limiterStatus `json:"rate_limit"` // quota of calls to providers, per shipper
+}
+
+// RequestAPIKeyCreate - api key to be issued
+type requestAPIKeyCreate struct {
+	Name    string   `json:"name"` // who uses it (ex.: storefront)
+	Scopes  []string `json:"scopes"`
+	Shipper string   `json:"shipper,omitempty"` // registered number of its tenant (default: the configured shipper)
+}
+
+// ResponseAPIKey - issued api key, along with the key itself (shown only once)
+type responseAPIKey struct {
+	data.APIKey
+	Key string `json:"key"`
+}
+
+// RequestTenant - tenant to be stored (the token is write only, it is never sent back)
+type requestTenant struct {
+	Name             string        `json:"name"`
+	RegisteredNumber string        `json:"registered_number"` // can not be changed once the tenant is stored
+	Token            string        `json:"token"`             // kept when updating without it
+	PlatformCode     string        `json:"platform_code"`
+	Origins          []data.Origin `json:"origins"`
+}
 
 // RequestQuote -
 type requestQuote struct {
 	Recipient recipientQuote `json:"recipient"`
 	Volumes   []volume       `json:"volumes"`
+	Origins   []origin       `json:"origins,omitempty"` // default: every configured warehouse
+}
+
+// Origin - warehouse (dispatcher) that ships the volumes
+type origin struct {
+	Zipcode          string `json:"zipcode"`
+	RegisteredNumber string `json:"registered_number,omitempty"` // default: shipper's registered number
 }
 
 // RecipientQuote -
@@ -104,8 +177,8 @@ type offer struct {
 	Service                     string                      `json:"service"`
 	DeliveryTime                deliveryTime                `json:"delivery_time,omitempty"`
 	Expiration                  time.Time                   `json:"expiration"`
-	CostPrice                   float64                     `json:"cost_price"`
-	FinalPrice                  float64                     `json:"final_price"`
+	CostPrice                   data.Money                  `json:"cost_price"`
+	FinalPrice                  data.Money                  `json:"final_price"`
 	Weights                     weights                     `json:"weights"`
 	OriginalDeliveryTime        originalDeliveryTime        `json:"original_delivery_time,omitempty"`
 	HomeDelivery                bool                        `json:"home_delivery"`
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// QuoteProvider - interface to be implemented by every source of carrier quotes
+// (freight aggregators, in-house rate tables, ...)
+type QuoteProvider interface {
+	// Name - identifies the provider in configuration and logs
+	Name() string
+	// Quote - builds the provider's request from user's input, calls it and normalizes its offers
+	Quote(ctx context.Context, reqQuote requestQuote) ([]data.Carrier, error)
+}
+
+// providerFactory - creates a provider using the app's shared dependencies (http client, ...)
+type providerFactory func(app *Config) (QuoteProvider, error)
+
+// providers - registry of known providers, filled by each provider's init
+var providers = map[string]providerFactory{}
+
+// registerProvider - makes a provider available to be enabled by configuration
+func registerProvider(name string, factory providerFactory) {
+	providers[strings.ToLower(name)] = factory
+}
+
+// availableProviders - returns the names of every registered provider (sorted)
+func availableProviders() (names []string) {
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// setUpProviders - enables the listed providers (ex.: ["freterapido", "table"])
+func (app *Config) setUpProviders(names []string) error {
+	app.Providers = nil
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		factory, ok := providers[name]
+		if !ok {
+			return fmt.Errorf("unknown quote provider %q (available: %s)", name, strings.Join(availableProviders(), ", "))
+		}
+
+		provider, err := factory(app)
+		if err != nil {
+			return fmt.Errorf("failed to set up quote provider %q: %w", name, err)
+		}
+
+		app.Providers = append(app.Providers, provider)
+	}
+
+	return nil
+}
+
+// providerResult - offers (or error) returned by a single provider during fan-out
+type providerResult struct {
+	Name     string
+	Carriers []data.Carrier
+	Err      error
+}
+
+// fanOutQuote - calls every enabled provider concurrently (each one with its own deadline),
+// merging their offers into one entry and gathering the errors per provider
+func (app *Config) fanOutQuote(ctx context.Context, reqQuote requestQuote) (result data.QuoteEntry, errs map[string]error) {
+	timeout := app.Settings.ProviderTimeout
+	if timeout <= 0 {
+		timeout = defaultProviderTimeout
+	}
+
+	// results keep providers' order, so merge is deterministic
+	results := make([]providerResult, len(app.Providers))
+
+	var wg sync.WaitGroup
+	for key, provider := range app.Providers {
+		wg.Add(1)
+		go func(key int, provider QuoteProvider) {
+			defer wg.Done()
+
+			providerCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			carriers, err := provider.Quote(providerCtx, reqQuote)
+			results[key] = providerResult{Name: provider.Name(), Carriers: carriers, Err: err}
+		}(key, provider)
+	}
+	wg.Wait()
+
+	// merge offers
+	errs = make(map[string]error)
+	for _, res := range results {
+		if res.Err != nil {
+			errs[res.Name] = res.Err
+			continue
+		}
+
+		for _, carrier := range res.Carriers {
+			carrier.Provider = res.Name
+			result.Carrier = append(result.Carrier, carrier)
+		}
+	}
+
+	result.Carrier = dedupeCarriers(result.Carrier)
+
+	return result, errs
+}
+
+// dedupeCarriers - removes repeated offers (same carrier, service, deadline, price and origin), keeping the first one
+func dedupeCarriers(carriers []data.Carrier) []data.Carrier {
+	type offerKey struct {
+		Name     string
+		Service  string
+		Deadline int
+		Price    data.Money
+		Origin   string
+	}
+
+	seen := make(map[offerKey]bool)
+	unique := make([]data.Carrier, 0, len(carriers))
+	for _, carrier := range carriers {
+		key := offerKey{carrier.Name, carrier.Service, carrier.Deadline, carrier.Price, carrier.Origin}
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		unique = append(unique, carrier)
+	}
+
+	return unique
+}
+
+// groupByOrigin - splits offers per origin (warehouse), keeping the order in which origins first appear
+func groupByOrigin(carriers []data.Carrier) (origins []originQuote) {
+	index := make(map[string]int)
+	for _, carrier := range carriers {
+		key, ok := index[carrier.Origin]
+		if !ok {
+			key = len(origins)
+			index[carrier.Origin] = key
+			origins = append(origins, originQuote{Zipcode: carrier.Origin})
+		}
+
+		origins[key].Carrier = append(origins[key].Carrier, carrier)
+	}
+
+	return origins
+}
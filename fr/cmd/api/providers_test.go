@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// stubProvider - provider with canned offers to be used in tests
+type stubProvider struct {
+	name     string
+	carriers []data.Carrier
+	err      error
+	delay    time.Duration
+}
+
+func (p *stubProvider) Name() string {
+	return p.name
+}
+
+func (p *stubProvider) Quote(ctx context.Context, reqQuote requestQuote) ([]data.Carrier, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return p.carriers, p.err
+}
+
+func TestConfig_setUpProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		list      []string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "test #1 - default provider",
+			list:      defaultSettings().Providers,
+			wantNames: []string{"freterapido"},
+			wantErr:   false,
+		},
+		{
+			name:      "test #2 - explicit provider (case and spaces)",
+			list:      []string{" FreteRapido "},
+			wantNames: []string{"freterapido"},
+			wantErr:   false,
+		},
+		{
+			name:      "test #3 - unknown provider",
+			list:      []string{"freterapido", "unknown"},
+			wantNames: nil,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Config{Client: &http.Client{}, Settings: defaultSettings()}
+			app.Settings.FreteRapido.Zipcode = "29161376"
+
+			err := app.setUpProviders(tt.list)
+
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.setUpProviders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(app.Providers) != len(tt.wantNames) {
+				t.Fatalf("Config.setUpProviders() providers = %d, want %d", len(app.Providers), len(tt.wantNames))
+			}
+
+			for key, provider := range app.Providers {
+				if provider.Name() != tt.wantNames[key] {
+					t.Errorf("Config.setUpProviders() provider[%d] = %s, want %s", key, provider.Name(), tt.wantNames[key])
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_fanOutQuote(t *testing.T) {
+	tests := []struct {
+		name       string
+		providers  []QuoteProvider
+		wantResult data.QuoteEntry
+		wantErrs   map[string]string
+	}{
+		{
+			name: "test #1 - merged and de-duplicated offers",
+			providers: []QuoteProvider{
+				&stubProvider{name: "a", carriers: []data.Carrier{
+					{Name: "CORREIOS", Service: "Rodoviário", Deadline: 5, Price: 1000},
+					{Name: "CORREIOS", Service: "Rodoviário", Deadline: 5, Price: 1000},
+				}},
+				&stubProvider{name: "b", carriers: []data.Carrier{
+					{Name: "CORREIOS", Service: "Rodoviário", Deadline: 5, Price: 1000},
+					{Name: "AZUL CARGO", Service: "Aéreo", Deadline: 2, Price: 4182},
+				}},
+			},
+			wantResult: data.QuoteEntry{
+				Carrier: []data.Carrier{
+					{Name: "CORREIOS", Service: "Rodoviário", Deadline: 5, Price: 1000, Provider: "a"},
+					{Name: "AZUL CARGO", Service: "Aéreo", Deadline: 2, Price: 4182, Provider: "b"},
+				},
+			},
+			wantErrs: map[string]string{},
+		},
+		{
+			name: "test #2 - partial failure",
+			providers: []QuoteProvider{
+				&stubProvider{name: "a", err: errors.New("unavailable")},
+				&stubProvider{name: "b", carriers: []data.Carrier{{Name: "test", Price: 150}}},
+			},
+			wantResult: data.QuoteEntry{
+				Carrier: []data.Carrier{{Name: "test", Price: 150, Provider: "b"}},
+			},
+			wantErrs: map[string]string{"a": "unavailable"},
+		},
+		{
+			name: "test #3 - provider deadline exceeded",
+			providers: []QuoteProvider{
+				&stubProvider{name: "slow", delay: time.Second},
+			},
+			wantResult: data.QuoteEntry{Carrier: []data.Carrier{}},
+			wantErrs:   map[string]string{"slow": context.DeadlineExceeded.Error()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Config{Providers: tt.providers}
+			app.Settings.ProviderTimeout = 50 * time.Millisecond
+
+			gotResult, gotErrs := app.fanOutQuote(context.Background(), requestQuote{})
+
+			if !reflect.DeepEqual(gotResult, tt.wantResult) {
+				t.Errorf("Config.fanOutQuote() result = %v, want %v", gotResult, tt.wantResult)
+			}
+
+			gotMessages := make(map[string]string, len(gotErrs))
+			for name, err := range gotErrs {
+				gotMessages[name] = err.Error()
+			}
+
+			if !reflect.DeepEqual(gotMessages, tt.wantErrs) {
+				t.Errorf("Config.fanOutQuote() errs = %v, want %v", gotMessages, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestGroupByOrigin(t *testing.T) {
+	carriers := []data.Carrier{
+		{Name: "a", Origin: "29161376"},
+		{Name: "b", Origin: "01311000"},
+		{Name: "c", Origin: "29161376"},
+	}
+
+	want := []originQuote{
+		{Zipcode: "29161376", Carrier: []data.Carrier{carriers[0], carriers[2]}},
+		{Zipcode: "01311000", Carrier: []data.Carrier{carriers[1]}},
+	}
+
+	got := groupByOrigin(carriers)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupByOrigin() = %v, want %v", got, want)
+	}
+}
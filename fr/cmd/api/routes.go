@@ -6,26 +6,56 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/mtrdgs/fr/data"
 )
 
 func (app *Config) routes() http.Handler {
 	r := chi.NewRouter()
 
-	// who is allowed to connect?
+	// who is allowed to connect? (api keys are sent in headers, so cookies are not needed)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://*", "https://*"},
-		AllowedMethods:   []string{"GET", "POST"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
+		AllowedOrigins:   app.Settings.CORS.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-API-Key", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link", "Cache-Status", "Retry-After"},
+		AllowCredentials: false,
 		MaxAge:           300,
 	}))
 
 	r.Use(middleware.Heartbeat("/ping"))
 
 	r.Post("/", app.Fr)
-	r.Post("/quote", app.Quote)
-	r.Get("/metrics", app.Metrics)
+
+	// every other route needs an api key with the route's scope
+	r.Group(func(r chi.Router) {
+		r.Use(app.authenticate)
+
+		r.With(app.requireScope(data.ScopeQuoteCreate)).Post("/quote", app.Quote)
+		r.With(app.requireScope(data.ScopeQuoteRead)).Get("/quotes", app.Quotes)
+		r.With(app.requireScope(data.ScopeQuoteRead)).Get("/quotes/{id}", app.QuoteByID)
+		r.With(app.requireScope(data.ScopeQuoteRead)).Post("/quotes/{id}/validate", app.ValidateQuote)
+		r.With(app.requireScope(data.ScopeMetricsRead)).Get("/metrics", app.Metrics)
+		r.With(app.requireScope(data.ScopeStatusRead)).Get("/status", app.Status)
+
+		// shipper's rules
+		r.With(app.requireScope(data.ScopeRulesRead)).Get("/rules", app.Rules)
+		r.With(app.requireScope(data.ScopeRulesWrite)).Post("/rules", app.CreateRule)
+		r.With(app.requireScope(data.ScopeRulesRead)).Get("/rules/{id}", app.RuleByID)
+		r.With(app.requireScope(data.ScopeRulesWrite)).Put("/rules/{id}", app.UpdateRule)
+		r.With(app.requireScope(data.ScopeRulesWrite)).Delete("/rules/{id}", app.DeleteRule)
+
+		// api keys
+		r.With(app.requireScope(data.ScopeKeysAdmin)).Get("/keys", app.APIKeys)
+		r.With(app.requireScope(data.ScopeKeysAdmin)).Post("/keys", app.CreateAPIKey)
+		r.With(app.requireScope(data.ScopeKeysAdmin)).Delete("/keys/{id}", app.RevokeAPIKey)
+
+		// tenants (shippers with their own credentials)
+		r.With(app.requireScope(data.ScopeTenantsAdmin)).Get("/tenants", app.Tenants)
+		r.With(app.requireScope(data.ScopeTenantsAdmin)).Post("/tenants", app.CreateTenant)
+		r.With(app.requireScope(data.ScopeTenantsAdmin)).Get("/tenants/{id}", app.TenantByID)
+		r.With(app.requireScope(data.ScopeTenantsAdmin)).Put("/tenants/{id}", app.UpdateTenant)
+		r.With(app.requireScope(data.ScopeTenantsAdmin)).Delete("/tenants/{id}", app.DeleteTenant)
+	})
 
 	return r
 }
@@ -14,7 +14,7 @@ func TestConfig_routes(t *testing.T) {
 	testRoutes := testApp.routes()
 	chiRoutes := testRoutes.(chi.Router)
 
-	routes := []string{"/", "/quote", "/metrics"}
+	routes := []string{"/", "/quote", "/quotes", "/quotes/{id}", "/quotes/{id}/validate", "/metrics", "/status", "/rules", "/rules/{id}", "/keys", "/keys/{id}", "/tenants", "/tenants/{id}"}
 
 	for _, route := range routes {
 		routeExists(t, chiRoutes, route)
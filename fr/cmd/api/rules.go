@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// ruleStages - order in which types of rules are applied (offers are hidden first, then prices change)
+var ruleStages = map[string]int{
+	data.RuleHideCarrier:      1,
+	data.RuleMaxDeadline:      2,
+	data.RuleMarkupPercentage: 3,
+	data.RuleMarkupFixed:      4,
+	data.RuleStateDiscount:    5,
+	data.RuleFreeShipping:     6,
+}
+
+// applyRules - applies shipper's rules to the offers (hiding, capping, marking up and discounting them)
+// rules are applied by stage (see ruleStages), then by priority. each changed offer lists the rules applied to it
+func applyRules(carriers []data.Carrier, rules []data.Rule, req *data.QuoteRequest) []data.Carrier {
+	// enabled rules, by stage (the order of rules in the same stage is kept)
+	active := make([]data.Rule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Disabled {
+			active = append(active, rule)
+		}
+	}
+
+	sort.SliceStable(active, func(i, j int) bool {
+		return ruleStages[active[i].Type] < ruleStages[active[j].Type]
+	})
+
+	result := make([]data.Carrier, 0, len(carriers))
+	for _, carrier := range carriers {
+		basePrice := carrier.Price
+		hidden := false
+
+		for _, rule := range active {
+			if !ruleMatches(rule, carrier, req) {
+				continue
+			}
+
+			switch rule.Type {
+			case data.RuleHideCarrier, data.RuleMaxDeadline:
+				hidden = true
+			case data.RuleMarkupPercentage:
+				carrier.Price += carrier.Price.Percent(rule.Percentage)
+			case data.RuleMarkupFixed:
+				carrier.Price += rule.Amount
+			case data.RuleStateDiscount:
+				carrier.Price = max(carrier.Price-carrier.Price.Percent(rule.Percentage), 0)
+			case data.RuleFreeShipping:
+				carrier.Price = 0
+			}
+
+			if hidden {
+				break
+			}
+
+			carrier.Rules = append(carrier.Rules, data.AppliedRule{ID: rule.ID.Hex(), Name: rule.Name, Type: rule.Type})
+		}
+
+		if hidden {
+			continue
+		}
+
+		if carrier.Price != basePrice {
+			carrier.BasePrice = &basePrice
+			carrier.SetMargin()
+		}
+
+		result = append(result, carrier)
+	}
+
+	return result
+}
+
+// ruleMatches - checks if a rule applies to an offer (of a quote to a destination, with a cart value)
+func ruleMatches(rule data.Rule, carrier data.Carrier, req *data.QuoteRequest) bool {
+	if len(rule.Carriers) > 0 && !containsFold(rule.Carriers, carrier.Name) {
+		return false
+	}
+
+	switch rule.Type {
+	case data.RuleMaxDeadline:
+		return carrier.Deadline > rule.MaxDeadline
+	case data.RuleStateDiscount:
+		return req != nil && containsFold(rule.States, req.Destination.State)
+	case data.RuleFreeShipping:
+		return req != nil && req.DeclaredValue >= rule.MinCartValue
+	default:
+		return true
+	}
+}
+
+// checkRule - verifies if a rule has every argument its type needs
+func checkRule(rule data.Rule) (args []string) {
+	args = make([]string, 0)
+
+	if strings.TrimSpace(rule.Name) == "" {
+		args = append(args, "Name is required")
+	}
+
+	switch rule.Type {
+	case data.RuleHideCarrier:
+		if len(rule.Carriers) == 0 {
+			args = append(args, "Carriers are required to hide carriers")
+		}
+	case data.RuleMaxDeadline:
+		if rule.MaxDeadline <= 0 {
+			args = append(args, "Max deadline must be positive")
+		}
+	case data.RuleMarkupPercentage:
+		if rule.Percentage <= 0 {
+			args = append(args, "Percentage must be positive")
+		}
+	case data.RuleMarkupFixed:
+		if rule.Amount <= 0 {
+			args = append(args, "Amount must be positive")
+		}
+	case data.RuleStateDiscount:
+		if len(rule.States) == 0 {
+			args = append(args, "States are required for state discounts")
+		}
+		if rule.Percentage <= 0 || rule.Percentage > 100 {
+			args = append(args, "Percentage must be between 0 and 100")
+		}
+	case data.RuleFreeShipping:
+		if rule.MinCartValue < 0 {
+			args = append(args, "Min cart value can not be negative")
+		}
+	default:
+		args = append(args, fmt.Sprintf("Type is invalid (%s, %s, %s, %s, %s or %s)", data.RuleHideCarrier, data.RuleMaxDeadline,
+			data.RuleMarkupPercentage, data.RuleMarkupFixed, data.RuleStateDiscount, data.RuleFreeShipping))
+	}
+
+	return args
+}
+
+// containsFold - checks if a list has a value (case insensitive)
+func containsFold(values []string, value string) bool {
+	for _, item := range values {
+		if strings.EqualFold(strings.TrimSpace(item), value) {
+			return true
+		}
+	}
+
+	return false
+}
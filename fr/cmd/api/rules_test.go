@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mtrdgs/fr/data"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApplyRules(t *testing.T) {
+	carriers := []data.Carrier{
+		{Name: "CORREIOS", Deadline: 5, Price: 10000},
+		{Name: "AZUL CARGO", Deadline: 2, Price: 4182},
+		{Name: "BTU BRASPRESS", Deadline: 12, Price: 9335},
+	}
+
+	request := &data.QuoteRequest{Destination: data.Destination{Zipcode: "01311000", State: "SP"}, DeclaredValue: 90500}
+
+	// rule with a fixed id, so applied rules can be compared
+	newRule := func(id byte, rule data.Rule) data.Rule {
+		rule.ID = primitive.ObjectID{id}
+		return rule
+	}
+
+	hide := newRule(1, data.Rule{Name: "no azul", Type: data.RuleHideCarrier, Carriers: []string{"azul cargo"}})
+	maxDeadline := newRule(2, data.Rule{Name: "10 days", Type: data.RuleMaxDeadline, MaxDeadline: 10})
+	markup := newRule(3, data.Rule{Name: "+10%", Type: data.RuleMarkupPercentage, Percentage: 10})
+	fixed := newRule(4, data.Rule{Name: "+1.50 correios", Type: data.RuleMarkupFixed, Amount: 150, Carriers: []string{"CORREIOS"}})
+	discount := newRule(5, data.Rule{Name: "-50% SP", Type: data.RuleStateDiscount, Percentage: 50, States: []string{"sp"}})
+	freeShipping := newRule(6, data.Rule{Name: "free over 1000", Type: data.RuleFreeShipping, MinCartValue: 100000})
+	cheapFreeShipping := newRule(7, data.Rule{Name: "free over 500", Type: data.RuleFreeShipping, MinCartValue: 50000, Carriers: []string{"AZUL CARGO"}})
+
+	applied := func(rules ...data.Rule) (result []data.AppliedRule) {
+		for _, rule := range rules {
+			result = append(result, data.AppliedRule{ID: rule.ID.Hex(), Name: rule.Name, Type: rule.Type})
+		}
+		return result
+	}
+	price := func(value data.Money) *data.Money {
+		return &value
+	}
+
+	tests := []struct {
+		name         string
+		rules        []data.Rule
+		wantCarriers []data.Carrier
+	}{
+		{name: "test #1 - no rules", rules: nil, wantCarriers: carriers},
+		{
+			name:  "test #2 - hidden carriers and max deadline",
+			rules: []data.Rule{hide, maxDeadline},
+			wantCarriers: []data.Carrier{
+				{Name: "CORREIOS", Deadline: 5, Price: 10000},
+			},
+		},
+		{
+			name:  "test #3 - markups and discounts (by stage, not by order)",
+			rules: []data.Rule{discount, fixed, markup},
+			wantCarriers: []data.Carrier{
+				{Name: "CORREIOS", Deadline: 5, Price: 5575, BasePrice: price(10000), Rules: applied(markup, fixed, discount)},
+				{Name: "AZUL CARGO", Deadline: 2, Price: 2300, BasePrice: price(4182), Rules: applied(markup, discount)},
+				{Name: "BTU BRASPRESS", Deadline: 12, Price: 5134, BasePrice: price(9335), Rules: applied(markup, discount)},
+			},
+		},
+		{
+			name:  "test #4 - free shipping by cart value",
+			rules: []data.Rule{freeShipping, cheapFreeShipping},
+			wantCarriers: []data.Carrier{
+				{Name: "CORREIOS", Deadline: 5, Price: 10000},
+				{Name: "AZUL CARGO", Deadline: 2, Price: 0, BasePrice: price(4182), Rules: applied(cheapFreeShipping)},
+				{Name: "BTU BRASPRESS", Deadline: 12, Price: 9335},
+			},
+		},
+		{
+			name: "test #5 - disabled rules",
+			rules: []data.Rule{
+				newRule(8, data.Rule{Name: "off", Type: data.RuleHideCarrier, Carriers: []string{"CORREIOS"}, Disabled: true}),
+			},
+			wantCarriers: carriers,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCarriers := applyRules(carriers, tt.rules, request)
+
+			if !reflect.DeepEqual(gotCarriers, tt.wantCarriers) {
+				t.Errorf("applyRules() = %+v, want %+v", gotCarriers, tt.wantCarriers)
+			}
+		})
+	}
+}
+
+func TestApplyRules_margin(t *testing.T) {
+	cost := data.Money(3000)
+	carriers := []data.Carrier{{Name: "CORREIOS", Price: 4000, CostPrice: &cost}}
+	carriers[0].SetMargin()
+
+	rules := []data.Rule{{Name: "+10%", Type: data.RuleMarkupPercentage, Percentage: 10}}
+
+	gotCarriers := applyRules(carriers, rules, &data.QuoteRequest{})
+
+	// margin follows the price changed by the rules
+	if gotCarriers[0].Margin == nil || *gotCarriers[0].Margin != 1400 {
+		t.Errorf("applyRules() margin = %v, want 1400", gotCarriers[0].Margin)
+	}
+
+	// offers passed are kept as they were
+	if *carriers[0].Margin != 1000 {
+		t.Errorf("applyRules() changed the original margin to %v", *carriers[0].Margin)
+	}
+}
+
+func TestCheckRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     data.Rule
+		wantArgs []string
+	}{
+		{
+			name:     "test #1 - valid rule",
+			rule:     data.Rule{Name: "+10%", Type: data.RuleMarkupPercentage, Percentage: 10},
+			wantArgs: []string{},
+		},
+		{
+			name:     "test #2 - hide without carriers",
+			rule:     data.Rule{Name: "hide", Type: data.RuleHideCarrier},
+			wantArgs: []string{"Carriers are required to hide carriers"},
+		},
+		{
+			name:     "test #3 - invalid state discount",
+			rule:     data.Rule{Type: data.RuleStateDiscount, Percentage: 120},
+			wantArgs: []string{"Name is required", "States are required for state discounts", "Percentage must be between 0 and 100"},
+		},
+		{
+			name:     "test #4 - unknown type",
+			rule:     data.Rule{Name: "unknown", Type: "cashback"},
+			wantArgs: []string{"Type is invalid (hide_carrier, max_deadline, markup_percentage, markup_fixed, state_discount or free_shipping)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotArgs := checkRule(tt.rule); !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("checkRule() = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
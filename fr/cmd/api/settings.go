@@ -0,0 +1,478 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtrdgs/fr/data"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultPort            = "80"
+	defaultMongoURL        = "mongodb://mongo:27017"
+	defaultAPIURL          = "https://sp.freterapido.com/api/v3/quote/simulate"
+	defaultProviderTimeout = 10 * time.Second
+	defaultCacheBackend    = "memory"
+	defaultCacheTTL        = 30 * time.Minute
+	defaultConnectTimeout  = 3 * time.Second
+	defaultResponseTimeout = 8 * time.Second
+	defaultRetries         = 2
+	defaultRetryBackoff    = 200 * time.Millisecond
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+	defaultRateLimit       = 10
+	defaultRateLimitBurst  = 10
+	defaultRateLimitQueue  = 50
+	minAdminKeyLength      = 32
+)
+
+// settings - typed configuration of the app, loaded once at startup
+// from an optional yaml/json file (CONFIG_FILE) and then from env vars (which take precedence)
+type settings struct {
+	Port            string              `yaml:"port"`
+	Mongo           mongoSettings       `yaml:"mongo"`
+	FreteRapido     freteRapidoSettings `yaml:"freterapido"`
+	Providers       []string            `yaml:"providers"`
+	ProviderTimeout time.Duration       `yaml:"provider_timeout"`
+	Metrics         metricsSettings     `yaml:"metrics"`
+	Cache           cacheSettings       `yaml:"cache"`
+	Client          clientSettings      `yaml:"client"`
+	RateLimit       rateLimitSettings   `yaml:"rate_limit"`
+	Auth            authSettings        `yaml:"auth"`
+	CORS            corsSettings        `yaml:"cors"`
+}
+
+// mongoSettings - connection to mongo
+type mongoSettings struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// freteRapidoSettings - freterapido api and shipper's credentials
+type freteRapidoSettings struct {
+	URL              string           `yaml:"url"`
+	RegisteredNumber string           `yaml:"registered_number"`
+	Token            string           `yaml:"token"`
+	PlatformCode     string           `yaml:"platform_code"`
+	Zipcode          string           `yaml:"zipcode"` // origin zipcode(s), comma separated
+	Origins          []originSettings `yaml:"origins"` // takes precedence over zipcode
+}
+
+// originSettings - a warehouse (dispatcher) that ships the volumes
+type originSettings struct {
+	RegisteredNumber string `yaml:"registered_number"` // default: shipper's registered number
+	Zipcode          string `yaml:"zipcode"`
+}
+
+// metricsSettings - how offers are aggregated in metrics
+type metricsSettings struct {
+	Exclude []string `yaml:"exclude"` // offers left out of metrics: zero_price and/or invalid
+}
+
+// cacheSettings - cache of providers' responses
+type cacheSettings struct {
+	Backend string        `yaml:"backend"` // none, memory or mongo
+	TTL     time.Duration `yaml:"ttl"`     // max time a response is cached (less, when its offers expire before)
+}
+
+// clientSettings - http client that calls providers (timeouts, retries and circuit breaker)
+type clientSettings struct {
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`  // to open a connection (and its tls handshake)
+	ResponseTimeout time.Duration `yaml:"response_timeout"` // for the response headers, on each attempt
+	Retries         int           `yaml:"retries"`          // extra attempts after a retryable failure (0 to disable)
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`    // base wait between attempts (doubled each time, with jitter)
+	BreakerFailures int           `yaml:"breaker_failures"` // consecutive failures that open the circuit (0 to disable)
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"` // time the circuit stays open before a trial request
+}
+
+// rateLimitSettings - quota of calls to providers, per shipper credential (token bucket)
+type rateLimitSettings struct {
+	Rate  float64 `yaml:"rate"`  // calls per second (0 to disable)
+	Burst int     `yaml:"burst"` // calls allowed at once
+	Queue int     `yaml:"queue"` // calls waiting for their turn (more than that are rejected with 429)
+}
+
+// authSettings - authentication of api clients (api keys)
+type authSettings struct {
+	Enabled  bool   `yaml:"enabled"`
+	AdminKey string `yaml:"admin_key"` // key with every scope, used to issue the others
+}
+
+// corsSettings - browsers' origins allowed to call the api
+type corsSettings struct {
+	AllowedOrigins []string `yaml:"allowed_origins"` // ex.: https://*.mystore.com
+}
+
+// defaultSettings - settings used when nothing else is set
+func defaultSettings() settings {
+	return settings{
+		Port: defaultPort,
+		Mongo: mongoSettings{
+			URL: defaultMongoURL,
+		},
+		FreteRapido: freteRapidoSettings{
+			URL: defaultAPIURL,
+		},
+		Providers:       []string{"freterapido"},
+		ProviderTimeout: defaultProviderTimeout,
+		Metrics: metricsSettings{
+			Exclude: []string{data.ExcludedInvalid},
+		},
+		Cache: cacheSettings{
+			Backend: defaultCacheBackend,
+			TTL:     defaultCacheTTL,
+		},
+		Client: clientSettings{
+			ConnectTimeout:  defaultConnectTimeout,
+			ResponseTimeout: defaultResponseTimeout,
+			Retries:         defaultRetries,
+			RetryBackoff:    defaultRetryBackoff,
+			BreakerFailures: defaultBreakerFailures,
+			BreakerCooldown: defaultBreakerCooldown,
+		},
+		RateLimit: rateLimitSettings{
+			Rate:  defaultRateLimit,
+			Burst: defaultRateLimitBurst,
+			Queue: defaultRateLimitQueue,
+		},
+		Auth: authSettings{
+			Enabled: true,
+		},
+		CORS: corsSettings{
+			AllowedOrigins: []string{"http://*", "https://*"},
+		},
+	}
+}
+
+// loadSettings - loads and validates settings (defaults <- CONFIG_FILE <- env vars)
+func loadSettings() (s settings, err error) {
+	s = defaultSettings()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		err = s.loadFile(path)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	err = s.loadEnv()
+	if err != nil {
+		return s, err
+	}
+
+	return s, s.validate()
+}
+
+// loadFile - reads settings from a yaml (or json, as it is valid yaml) file
+func (s *settings) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	err = yaml.UnmarshalStrict(content, s)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv - overrides settings with the env vars that are set
+func (s *settings) loadEnv() error {
+	vars := map[string]*string{
+		"PORT":              &s.Port,
+		"MONGO_URL":         &s.Mongo.URL,
+		"MONGO_USERNAME":    &s.Mongo.Username,
+		"MONGO_PASSWORD":    &s.Mongo.Password,
+		"FRETERAPIDO_URL":   &s.FreteRapido.URL,
+		"REGISTERED_NUMBER": &s.FreteRapido.RegisteredNumber,
+		"TOKEN":             &s.FreteRapido.Token,
+		"PLATFORM_CODE":     &s.FreteRapido.PlatformCode,
+		"ZIPCODE":           &s.FreteRapido.Zipcode,
+		"QUOTE_CACHE":       &s.Cache.Backend,
+		"ADMIN_API_KEY":     &s.Auth.AdminKey,
+	}
+
+	for name, value := range vars {
+		if env, ok := os.LookupEnv(name); ok {
+			*value = strings.TrimSpace(env)
+		}
+	}
+
+	// ex.: QUOTE_PROVIDERS=freterapido,table
+	if env, ok := os.LookupEnv("QUOTE_PROVIDERS"); ok {
+		s.Providers = splitList(env)
+	}
+
+	// durations (ex.: QUOTE_CACHE_TTL=10m)
+	durations := map[string]*time.Duration{
+		"PROVIDER_TIMEOUT":        &s.ProviderTimeout,
+		"QUOTE_CACHE_TTL":         &s.Cache.TTL,
+		"CLIENT_CONNECT_TIMEOUT":  &s.Client.ConnectTimeout,
+		"CLIENT_RESPONSE_TIMEOUT": &s.Client.ResponseTimeout,
+		"CLIENT_RETRY_BACKOFF":    &s.Client.RetryBackoff,
+		"BREAKER_COOLDOWN":        &s.Client.BreakerCooldown,
+	}
+
+	for name, value := range durations {
+		if env, ok := os.LookupEnv(name); ok {
+			duration, err := time.ParseDuration(strings.TrimSpace(env))
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*value = duration
+		}
+	}
+
+	// numbers (ex.: CLIENT_RETRIES=3)
+	numbers := map[string]*int{
+		"CLIENT_RETRIES":   &s.Client.Retries,
+		"BREAKER_FAILURES": &s.Client.BreakerFailures,
+		"RATE_LIMIT_BURST": &s.RateLimit.Burst,
+		"RATE_LIMIT_QUEUE": &s.RateLimit.Queue,
+	}
+
+	for name, value := range numbers {
+		if env, ok := os.LookupEnv(name); ok {
+			number, err := strconv.Atoi(strings.TrimSpace(env))
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*value = number
+		}
+	}
+
+	// ex.: AUTH_ENABLED=false (every route open, only for development)
+	if env, ok := os.LookupEnv("AUTH_ENABLED"); ok {
+		enabled, err := strconv.ParseBool(strings.TrimSpace(env))
+		if err != nil {
+			return fmt.Errorf("invalid AUTH_ENABLED: %w", err)
+		}
+		s.Auth.Enabled = enabled
+	}
+
+	// ex.: CORS_ALLOWED_ORIGINS=https://mystore.com,https://*.mystore.com
+	if env, ok := os.LookupEnv("CORS_ALLOWED_ORIGINS"); ok {
+		s.CORS.AllowedOrigins = splitList(env)
+	}
+
+	// ex.: RATE_LIMIT=2.5 (calls per second)
+	if env, ok := os.LookupEnv("RATE_LIMIT"); ok {
+		rate, err := strconv.ParseFloat(strings.TrimSpace(env), 64)
+		if err != nil {
+			return fmt.Errorf("invalid RATE_LIMIT: %w", err)
+		}
+		s.RateLimit.Rate = rate
+	}
+
+	// ex.: METRICS_EXCLUDE=zero_price,invalid (empty to aggregate every offer)
+	if env, ok := os.LookupEnv("METRICS_EXCLUDE"); ok {
+		s.Metrics.Exclude = splitList(env)
+	}
+
+	return nil
+}
+
+// validate - verifies if every required setting is set (and valid)
+func (s settings) validate() error {
+	invalid := make([]string, 0)
+
+	if s.Port == "" {
+		invalid = append(invalid, "port (PORT) is required")
+	}
+
+	if s.Mongo.URL == "" {
+		invalid = append(invalid, "mongo.url (MONGO_URL) is required")
+	}
+
+	if len(s.Providers) == 0 {
+		invalid = append(invalid, "providers (QUOTE_PROVIDERS) must list at least one provider")
+	}
+
+	if s.ProviderTimeout <= 0 {
+		invalid = append(invalid, "provider_timeout (PROVIDER_TIMEOUT) must be positive")
+	}
+
+	if !cacheBackends[s.Cache.Backend] {
+		invalid = append(invalid, "cache.backend (QUOTE_CACHE) must be none, memory or mongo")
+	}
+
+	if s.Cache.TTL <= 0 {
+		invalid = append(invalid, "cache.ttl (QUOTE_CACHE_TTL) must be positive")
+	}
+
+	invalid = append(invalid, s.Client.validate()...)
+	invalid = append(invalid, s.RateLimit.validate()...)
+
+	if s.Auth.Enabled && len(s.Auth.AdminKey) < minAdminKeyLength {
+		invalid = append(invalid, fmt.Sprintf("auth.admin_key (ADMIN_API_KEY) must have at least %d characters when auth is enabled", minAdminKeyLength))
+	}
+
+	for _, reason := range s.Metrics.Exclude {
+		if reason != data.ExcludedZeroPrice && reason != data.ExcludedInvalid {
+			invalid = append(invalid, fmt.Sprintf("metrics.exclude (METRICS_EXCLUDE) has an unknown reason %q (zero_price or invalid)", reason))
+		}
+	}
+
+	if s.usesProvider("freterapido") {
+		invalid = append(invalid, s.FreteRapido.validate()...)
+	}
+
+	if len(invalid) > 0 {
+		return errors.New("invalid settings: " + strings.Join(invalid, "; "))
+	}
+
+	return nil
+}
+
+// validate - verifies freterapido's url and shipper's credentials
+func (fr freteRapidoSettings) validate() (invalid []string) {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"freterapido.url (FRETERAPIDO_URL)", fr.URL},
+		{"freterapido.registered_number (REGISTERED_NUMBER)", fr.RegisteredNumber},
+		{"freterapido.token (TOKEN)", fr.Token},
+		{"freterapido.platform_code (PLATFORM_CODE)", fr.PlatformCode},
+	}
+
+	for _, setting := range required {
+		if setting.value == "" {
+			invalid = append(invalid, setting.name+" is required")
+		}
+	}
+
+	// origins
+	if len(fr.Origins) == 0 {
+		if fr.Zipcode == "" {
+			invalid = append(invalid, "freterapido.zipcode (ZIPCODE) or freterapido.origins is required")
+		}
+
+		for _, origin := range fr.origins() {
+			if _, err := strconv.Atoi(origin.Zipcode); err != nil {
+				invalid = append(invalid, "freterapido.zipcode (ZIPCODE) must be numeric")
+				break
+			}
+		}
+	}
+
+	for key, origin := range fr.Origins {
+		if _, err := strconv.Atoi(origin.Zipcode); err != nil {
+			invalid = append(invalid, fmt.Sprintf("freterapido.origins[%d].zipcode must be numeric", key))
+		}
+	}
+
+	return invalid
+}
+
+// validate - verifies the client's timeouts, retries and circuit breaker
+func (c clientSettings) validate() (invalid []string) {
+	if c.ConnectTimeout <= 0 {
+		invalid = append(invalid, "client.connect_timeout (CLIENT_CONNECT_TIMEOUT) must be positive")
+	}
+
+	if c.ResponseTimeout <= 0 {
+		invalid = append(invalid, "client.response_timeout (CLIENT_RESPONSE_TIMEOUT) must be positive")
+	}
+
+	if c.Retries < 0 {
+		invalid = append(invalid, "client.retries (CLIENT_RETRIES) can not be negative")
+	}
+
+	if c.Retries > 0 && c.RetryBackoff <= 0 {
+		invalid = append(invalid, "client.retry_backoff (CLIENT_RETRY_BACKOFF) must be positive")
+	}
+
+	if c.BreakerFailures < 0 {
+		invalid = append(invalid, "client.breaker_failures (BREAKER_FAILURES) can not be negative")
+	}
+
+	if c.BreakerFailures > 0 && c.BreakerCooldown <= 0 {
+		invalid = append(invalid, "client.breaker_cooldown (BREAKER_COOLDOWN) must be positive")
+	}
+
+	return invalid
+}
+
+// validate - verifies the quota of calls to providers
+func (r rateLimitSettings) validate() (invalid []string) {
+	if r.Rate < 0 {
+		invalid = append(invalid, "rate_limit.rate (RATE_LIMIT) can not be negative")
+	}
+
+	if r.Rate > 0 && r.Burst < 1 {
+		invalid = append(invalid, "rate_limit.burst (RATE_LIMIT_BURST) must be at least 1")
+	}
+
+	if r.Queue < 0 {
+		invalid = append(invalid, "rate_limit.queue (RATE_LIMIT_QUEUE) can not be negative")
+	}
+
+	return invalid
+}
+
+// origins - returns every configured warehouse (from origins or from the comma separated zipcode)
+func (fr freteRapidoSettings) origins() (origins []originSettings) {
+	origins = append(origins, fr.Origins...)
+
+	if len(origins) == 0 {
+		for _, zipcode := range strings.Split(fr.Zipcode, ",") {
+			if zipcode = strings.TrimSpace(zipcode); zipcode != "" {
+				origins = append(origins, originSettings{Zipcode: zipcode})
+			}
+		}
+	}
+
+	for key := range origins {
+		if origins[key].RegisteredNumber == "" {
+			origins[key].RegisteredNumber = fr.RegisteredNumber
+		}
+	}
+
+	return origins
+}
+
+// usesProvider - checks if a provider is enabled
+func (s settings) usesProvider(name string) bool {
+	for _, provider := range s.Providers {
+		if strings.EqualFold(provider, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// policy - offers left out of metrics
+func (m metricsSettings) policy() (policy data.OfferPolicy) {
+	for _, reason := range m.Exclude {
+		switch reason {
+		case data.ExcludedZeroPrice:
+			policy.ExcludeZeroPrice = true
+		case data.ExcludedInvalid:
+			policy.ExcludeInvalid = true
+		}
+	}
+
+	return policy
+}
+
+// splitList - splits a comma separated env var, ignoring empty items
+func splitList(env string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(env, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadSettings(t *testing.T) {
+	// admin key set for every test (unless it is overridden)
+	const adminKey = "0123456789abcdef0123456789abcdef"
+
+	// valid shipper's credentials (as env vars)
+	validEnv := map[string]string{
+		"REGISTERED_NUMBER": "25438296000158",
+		"TOKEN":             "token",
+		"PLATFORM_CODE":     "platform",
+		"ZIPCODE":           "29161376",
+	}
+
+	tests := []struct {
+		name         string
+		env          map[string]string
+		file         string
+		wantSettings func() settings
+		wantErr      string
+	}{
+		{
+			name: "test #1 - defaults and env vars",
+			env:  validEnv,
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "25438296000158"
+				s.FreteRapido.Token = "token"
+				s.FreteRapido.PlatformCode = "platform"
+				s.FreteRapido.Zipcode = "29161376"
+				return s
+			},
+		},
+		{
+			name: "test #2 - yaml file overridden by env vars",
+			env: map[string]string{
+				"TOKEN":            "env-token",
+				"QUOTE_PROVIDERS":  "freterapido, table",
+				"PROVIDER_TIMEOUT": "3s",
+			},
+			file: `
+port: "8080"
+mongo:
+  url: mongodb://localhost:27017
+  username: admin
+  password: password
+freterapido:
+  registered_number: "25438296000158"
+  token: file-token
+  platform_code: platform
+  zipcode: "29161376"
+`,
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.Port = "8080"
+				s.Mongo = mongoSettings{URL: "mongodb://localhost:27017", Username: "admin", Password: "password"}
+				s.FreteRapido.RegisteredNumber = "25438296000158"
+				s.FreteRapido.Token = "env-token"
+				s.FreteRapido.PlatformCode = "platform"
+				s.FreteRapido.Zipcode = "29161376"
+				s.Providers = []string{"freterapido", "table"}
+				s.ProviderTimeout = 3 * time.Second
+				return s
+			},
+		},
+		{
+			name: "test #3 - json file",
+			env:  map[string]string{},
+			file: `{"freterapido": {"registered_number": "25438296000158", "token": "token", "platform_code": "platform", "zipcode": "29161376"}}`,
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "25438296000158"
+				s.FreteRapido.Token = "token"
+				s.FreteRapido.PlatformCode = "platform"
+				s.FreteRapido.Zipcode = "29161376"
+				return s
+			},
+		},
+		{
+			name:    "test #4 - missing shipper's credentials",
+			env:     map[string]string{"ZIPCODE": "29161376"},
+			wantErr: "freterapido.registered_number (REGISTERED_NUMBER) is required",
+		},
+		{
+			name:    "test #5 - invalid zipcode",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "abc"},
+			wantErr: "freterapido.zipcode (ZIPCODE) must be numeric",
+		},
+		{
+			name: "test #6 - multiple origins",
+			env:  map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "29161376, 01311000"},
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "1"
+				s.FreteRapido.Token = "1"
+				s.FreteRapido.PlatformCode = "1"
+				s.FreteRapido.Zipcode = "29161376, 01311000"
+				return s
+			},
+		},
+		{
+			name:    "test #7 - unknown key in file",
+			env:     validEnv,
+			file:    "unknown: true",
+			wantErr: "failed to parse config file",
+		},
+		{
+			name: "test #8 - metrics exclusion policy",
+			env:  map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "METRICS_EXCLUDE": "zero_price, invalid"},
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "1"
+				s.FreteRapido.Token = "1"
+				s.FreteRapido.PlatformCode = "1"
+				s.FreteRapido.Zipcode = "1"
+				s.Metrics.Exclude = []string{"zero_price", "invalid"}
+				return s
+			},
+		},
+		{
+			name:    "test #9 - unknown exclusion reason",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "METRICS_EXCLUDE": "cheap"},
+			wantErr: `metrics.exclude (METRICS_EXCLUDE) has an unknown reason "cheap"`,
+		},
+		{
+			name: "test #10 - cache",
+			env:  map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "QUOTE_CACHE": "mongo", "QUOTE_CACHE_TTL": "5m"},
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "1"
+				s.FreteRapido.Token = "1"
+				s.FreteRapido.PlatformCode = "1"
+				s.FreteRapido.Zipcode = "1"
+				s.Cache = cacheSettings{Backend: "mongo", TTL: 5 * time.Minute}
+				return s
+			},
+		},
+		{
+			name:    "test #11 - unknown cache backend",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "QUOTE_CACHE": "redis"},
+			wantErr: "cache.backend (QUOTE_CACHE) must be none, memory or mongo",
+		},
+		{
+			name: "test #12 - client",
+			env: map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "CLIENT_CONNECT_TIMEOUT": "1s",
+				"CLIENT_RESPONSE_TIMEOUT": "4s", "CLIENT_RETRIES": "0", "CLIENT_RETRY_BACKOFF": "1s", "BREAKER_FAILURES": "3", "BREAKER_COOLDOWN": "1m"},
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "1"
+				s.FreteRapido.Token = "1"
+				s.FreteRapido.PlatformCode = "1"
+				s.FreteRapido.Zipcode = "1"
+				s.Client = clientSettings{ConnectTimeout: time.Second, ResponseTimeout: 4 * time.Second, RetryBackoff: time.Second, BreakerFailures: 3, BreakerCooldown: time.Minute}
+				return s
+			},
+		},
+		{
+			name:    "test #13 - invalid retries",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "CLIENT_RETRIES": "many"},
+			wantErr: "invalid CLIENT_RETRIES",
+		},
+		{
+			name:    "test #14 - negative retries",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "CLIENT_RETRIES": "-1"},
+			wantErr: "client.retries (CLIENT_RETRIES) can not be negative",
+		},
+		{
+			name: "test #15 - rate limit",
+			env:  map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "RATE_LIMIT": "2.5", "RATE_LIMIT_BURST": "5", "RATE_LIMIT_QUEUE": "0"},
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "1"
+				s.FreteRapido.Token = "1"
+				s.FreteRapido.PlatformCode = "1"
+				s.FreteRapido.Zipcode = "1"
+				s.RateLimit = rateLimitSettings{Rate: 2.5, Burst: 5}
+				return s
+			},
+		},
+		{
+			name:    "test #16 - invalid rate limit burst",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "RATE_LIMIT_BURST": "0"},
+			wantErr: "rate_limit.burst (RATE_LIMIT_BURST) must be at least 1",
+		},
+		{
+			name: "test #17 - auth disabled and cors origins",
+			env: map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "AUTH_ENABLED": "false", "ADMIN_API_KEY": "",
+				"CORS_ALLOWED_ORIGINS": "https://mystore.com, https://*.mystore.com"},
+			wantSettings: func() settings {
+				s := defaultSettings()
+				s.FreteRapido.RegisteredNumber = "1"
+				s.FreteRapido.Token = "1"
+				s.FreteRapido.PlatformCode = "1"
+				s.FreteRapido.Zipcode = "1"
+				s.Auth = authSettings{Enabled: false}
+				s.CORS.AllowedOrigins = []string{"https://mystore.com", "https://*.mystore.com"}
+				return s
+			},
+		},
+		{
+			name:    "test #18 - short admin key",
+			env:     map[string]string{"REGISTERED_NUMBER": "1", "TOKEN": "1", "PLATFORM_CODE": "1", "ZIPCODE": "1", "ADMIN_API_KEY": "admin"},
+			wantErr: "auth.admin_key (ADMIN_API_KEY) must have at least 32 characters when auth is enabled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// start from a clean environment
+			for _, name := range []string{"CONFIG_FILE", "PORT", "MONGO_URL", "MONGO_USERNAME", "MONGO_PASSWORD", "FRETERAPIDO_URL",
+				"REGISTERED_NUMBER", "TOKEN", "PLATFORM_CODE", "ZIPCODE", "QUOTE_PROVIDERS", "PROVIDER_TIMEOUT", "METRICS_EXCLUDE",
+				"QUOTE_CACHE", "QUOTE_CACHE_TTL", "CLIENT_CONNECT_TIMEOUT", "CLIENT_RESPONSE_TIMEOUT", "CLIENT_RETRIES", "CLIENT_RETRY_BACKOFF",
+				"BREAKER_FAILURES", "BREAKER_COOLDOWN", "RATE_LIMIT", "RATE_LIMIT_BURST", "RATE_LIMIT_QUEUE", "AUTH_ENABLED",
+				"CORS_ALLOWED_ORIGINS"} {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			t.Setenv("ADMIN_API_KEY", adminKey)
+
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.yml")
+				_ = os.WriteFile(path, []byte(tt.file), 0o600)
+				t.Setenv("CONFIG_FILE", path)
+			}
+
+			gotSettings, err := loadSettings()
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadSettings() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("loadSettings() error = %v", err)
+			}
+
+			wantSettings := tt.wantSettings()
+			if _, ok := tt.env["ADMIN_API_KEY"]; !ok {
+				wantSettings.Auth.AdminKey = adminKey
+			}
+
+			if !reflect.DeepEqual(gotSettings, wantSettings) {
+				t.Errorf("loadSettings() = %+v, want %+v", gotSettings, wantSettings)
+			}
+		})
+	}
+}
+
+func TestFreteRapidoSettings_origins(t *testing.T) {
+	tests := []struct {
+		name        string
+		settings    freteRapidoSettings
+		wantOrigins []originSettings
+	}{
+		{
+			name:     "test #1 - comma separated zipcodes",
+			settings: freteRapidoSettings{RegisteredNumber: "1", Zipcode: "29161376, 01311000"},
+			wantOrigins: []originSettings{
+				{RegisteredNumber: "1", Zipcode: "29161376"},
+				{RegisteredNumber: "1", Zipcode: "01311000"},
+			},
+		},
+		{
+			name: "test #2 - origins take precedence",
+			settings: freteRapidoSettings{RegisteredNumber: "1", Zipcode: "29161376", Origins: []originSettings{
+				{Zipcode: "01311000"},
+				{RegisteredNumber: "2", Zipcode: "88010000"},
+			}},
+			wantOrigins: []originSettings{
+				{RegisteredNumber: "1", Zipcode: "01311000"},
+				{RegisteredNumber: "2", Zipcode: "88010000"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOrigins := tt.settings.origins()
+
+			if !reflect.DeepEqual(gotOrigins, tt.wantOrigins) {
+				t.Errorf("freteRapidoSettings.origins() = %v, want %v", gotOrigins, tt.wantOrigins)
+			}
+		})
+	}
+}
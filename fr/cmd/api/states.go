@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// zipcodeRange - range of zipcodes (first 5 digits) that belongs to a state
+type zipcodeRange struct {
+	From  int
+	To    int
+	State string
+}
+
+// zipcodeRanges - brazilian zipcode (CEP) ranges per state, by their first 5 digits
+var zipcodeRanges = []zipcodeRange{
+	{1000, 19999, "SP"},
+	{20000, 28999, "RJ"},
+	{29000, 29999, "ES"},
+	{30000, 39999, "MG"},
+	{40000, 48999, "BA"},
+	{49000, 49999, "SE"},
+	{50000, 56999, "PE"},
+	{57000, 57999, "AL"},
+	{58000, 58999, "PB"},
+	{59000, 59999, "RN"},
+	{60000, 63999, "CE"},
+	{64000, 64999, "PI"},
+	{65000, 65999, "MA"},
+	{66000, 68899, "PA"},
+	{68900, 68999, "AP"},
+	{69000, 69299, "AM"},
+	{69300, 69399, "RR"},
+	{69400, 69899, "AM"},
+	{69900, 69999, "AC"},
+	{70000, 72799, "DF"},
+	{72800, 72999, "GO"},
+	{73000, 73699, "DF"},
+	{73700, 76799, "GO"},
+	{76800, 76999, "RO"},
+	{77000, 77999, "TO"},
+	{78000, 78899, "MT"},
+	{79000, 79999, "MS"},
+	{80000, 87999, "PR"},
+	{88000, 89999, "SC"},
+	{90000, 99999, "RS"},
+}
+
+// stateFromZipcode - returns the state (ex.: SP) of a normalized zipcode (8 digits)
+func stateFromZipcode(zipcode string) string {
+	if len(zipcode) != 8 {
+		return data.UnknownState
+	}
+
+	prefix, err := strconv.Atoi(zipcode[:5])
+	if err != nil {
+		return data.UnknownState
+	}
+
+	for _, value := range zipcodeRanges {
+		if prefix >= value.From && prefix <= value.To {
+			return value.State
+		}
+	}
+
+	return data.UnknownState
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestStateFromZipcode(t *testing.T) {
+	tests := []struct {
+		name      string
+		zipcode   string
+		wantState string
+	}{
+		{name: "test #1 - são paulo", zipcode: "01311000", wantState: "SP"},
+		{name: "test #2 - espírito santo", zipcode: "29161376", wantState: "ES"},
+		{name: "test #3 - roraima (inside amazonas ranges)", zipcode: "69301000", wantState: "RR"},
+		{name: "test #4 - distrito federal", zipcode: "70040010", wantState: "DF"},
+		{name: "test #5 - rio grande do sul", zipcode: "90010000", wantState: "RS"},
+		{name: "test #6 - invalid zipcode", zipcode: "123", wantState: "unknown"},
+		{name: "test #7 - out of range", zipcode: "00000000", wantState: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotState := stateFromZipcode(tt.zipcode); gotState != tt.wantState {
+				t.Errorf("stateFromZipcode() = %v, want %v", gotState, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// sortings - valid values of the 'sort' param of /quote ("" keeps the providers' order)
+var sortings = map[string]bool{
+	"":         true,
+	"price":    true,
+	"deadline": true,
+}
+
+// quoteSummary - recommended offers of a quote
+type quoteSummary struct {
+	Cheapest  *data.Carrier `json:"cheapest"`   // lowest price (ties: fastest)
+	Fastest   *data.Carrier `json:"fastest"`    // lowest deadline (ties: cheapest)
+	BestValue *data.Carrier `json:"best_value"` // lowest score, combining price and deadline (see rankBestValue)
+}
+
+// sortCarriers - returns a sorted copy of the offers, by price or deadline (the other one breaks ties)
+func sortCarriers(carriers []data.Carrier, by string) []data.Carrier {
+	sorted := make([]data.Carrier, len(carriers))
+	copy(sorted, carriers)
+
+	switch by {
+	case "price":
+		sort.SliceStable(sorted, func(i, j int) bool { return cheaper(sorted[i], sorted[j]) })
+	case "deadline":
+		sort.SliceStable(sorted, func(i, j int) bool { return faster(sorted[i], sorted[j]) })
+	}
+
+	return sorted
+}
+
+// summarize - flags the cheapest, fastest and best value offers
+// priceWeight (0 to 1) is how much price matters in the best value score (the rest is deadline)
+func summarize(carriers []data.Carrier, priceWeight float64) *quoteSummary {
+	if len(carriers) == 0 {
+		return nil
+	}
+
+	// ranges of price and deadline
+	minPrice, maxPrice := carriers[0].Price, carriers[0].Price
+	minDeadline, maxDeadline := carriers[0].Deadline, carriers[0].Deadline
+	for _, carrier := range carriers {
+		minPrice, maxPrice = min(minPrice, carrier.Price), max(maxPrice, carrier.Price)
+		minDeadline, maxDeadline = min(minDeadline, carrier.Deadline), max(maxDeadline, carrier.Deadline)
+	}
+
+	score := func(carrier data.Carrier) float64 {
+		price := normalize(float64(carrier.Price), float64(minPrice), float64(maxPrice))
+		deadline := normalize(float64(carrier.Deadline), float64(minDeadline), float64(maxDeadline))
+
+		return priceWeight*price + (1-priceWeight)*deadline
+	}
+
+	summary := &quoteSummary{}
+	for key := range carriers {
+		carrier := &carriers[key]
+
+		if summary.Cheapest == nil || cheaper(*carrier, *summary.Cheapest) {
+			summary.Cheapest = carrier
+		}
+
+		if summary.Fastest == nil || faster(*carrier, *summary.Fastest) {
+			summary.Fastest = carrier
+		}
+
+		// ties: cheapest
+		if summary.BestValue == nil || score(*carrier) < score(*summary.BestValue) ||
+			(score(*carrier) == score(*summary.BestValue) && cheaper(*carrier, *summary.BestValue)) {
+			summary.BestValue = carrier
+		}
+	}
+
+	return summary
+}
+
+// cheaper - lower price, then lower deadline
+func cheaper(a, b data.Carrier) bool {
+	if a.Price != b.Price {
+		return a.Price < b.Price
+	}
+
+	return a.Deadline < b.Deadline
+}
+
+// faster - lower deadline, then lower price
+func faster(a, b data.Carrier) bool {
+	if a.Deadline != b.Deadline {
+		return a.Deadline < b.Deadline
+	}
+
+	return a.Price < b.Price
+}
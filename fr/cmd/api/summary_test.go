@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+func TestSortCarriers(t *testing.T) {
+	carriers := []data.Carrier{
+		{Name: "a", Deadline: 3, Price: 1000},
+		{Name: "b", Deadline: 1, Price: 1000},
+		{Name: "c", Deadline: 1, Price: 500},
+		{Name: "d", Deadline: 3, Price: 1000},
+	}
+
+	tests := []struct {
+		name      string
+		by        string
+		wantNames []string
+	}{
+		{name: "test #1 - unsorted", by: "", wantNames: []string{"a", "b", "c", "d"}},
+		{name: "test #2 - by price (ties by deadline, then stable)", by: "price", wantNames: []string{"c", "b", "a", "d"}},
+		{name: "test #3 - by deadline (ties by price)", by: "deadline", wantNames: []string{"c", "b", "a", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := sortCarriers(carriers, tt.by)
+
+			gotNames := make([]string, 0, len(sorted))
+			for _, carrier := range sorted {
+				gotNames = append(gotNames, carrier.Name)
+			}
+
+			if !reflect.DeepEqual(gotNames, tt.wantNames) {
+				t.Errorf("sortCarriers() = %v, want %v", gotNames, tt.wantNames)
+			}
+
+			// original offers are untouched
+			if carriers[0].Name != "a" || carriers[2].Name != "c" {
+				t.Errorf("sortCarriers() changed the original offers: %v", carriers)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name        string
+		carriers    []data.Carrier
+		priceWeight float64
+		want        []string // cheapest, fastest and best value
+	}{
+		{name: "test #1 - no offers", carriers: nil, priceWeight: 0.5, want: nil},
+		{
+			name:        "test #2 - single offer",
+			carriers:    []data.Carrier{{Name: "a", Deadline: 3, Price: 1000}},
+			priceWeight: 0.5,
+			want:        []string{"a", "a", "a"},
+		},
+		{
+			name: "test #3 - ties",
+			carriers: []data.Carrier{
+				{Name: "a", Deadline: 5, Price: 500},
+				{Name: "b", Deadline: 3, Price: 500},
+				{Name: "c", Deadline: 1, Price: 2000},
+				{Name: "d", Deadline: 1, Price: 1500},
+			},
+			priceWeight: 0.5,
+			want:        []string{"b", "d", "b"},
+		},
+		{
+			name: "test #4 - free offer",
+			carriers: []data.Carrier{
+				{Name: "a", Deadline: 5, Price: 1000},
+				{Name: "b", Deadline: 10, Price: 0},
+			},
+			priceWeight: 1,
+			want:        []string{"b", "a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := summarize(tt.carriers, tt.priceWeight)
+
+			var got []string
+			if summary != nil {
+				got = []string{summary.Cheapest.Name, summary.Fastest.Name, summary.BestValue.Name}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("summarize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
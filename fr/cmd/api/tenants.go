@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mtrdgs/fr/data"
+)
+
+// tenantContextKey - context key of the tenant the request acts on behalf of
+type tenantContextKey struct{}
+
+// withTenant - context carrying the tenant of a request (providers use its credentials)
+func withTenant(ctx context.Context, tenant data.Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// tenantFromContext - tenant of a request (false when it was not resolved, ex.: authentication is disabled)
+func tenantFromContext(ctx context.Context) (data.Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(data.Tenant)
+	return tenant, ok
+}
+
+// tenant - tenant of the authenticated caller (the default one when the caller has no tenant)
+func (app *Config) tenant(r *http.Request) data.Tenant {
+	if tenant, ok := tenantFromContext(r.Context()); ok {
+		return tenant
+	}
+
+	return app.defaultTenant()
+}
+
+// defaultTenant - shipper configured in settings (used by keys without tenant, and when authentication is disabled)
+func (app *Config) defaultTenant() data.Tenant {
+	settings := app.Settings.FreteRapido
+
+	tenant := data.Tenant{
+		Name:             "default",
+		RegisteredNumber: settings.RegisteredNumber,
+		Token:            settings.Token,
+		PlatformCode:     settings.PlatformCode,
+	}
+
+	for _, value := range settings.origins() {
+		tenant.Origins = append(tenant.Origins, data.Origin{Zipcode: value.Zipcode, RegisteredNumber: value.RegisteredNumber})
+	}
+
+	return tenant
+}
+
+// findTenant - tenant of an api key (stored in mongo, or the default one when the key has no tenant)
+func (app *Config) findTenant(apiKey data.APIKey) (data.Tenant, error) {
+	if apiKey.Shipper == "" {
+		return app.defaultTenant(), nil
+	}
+
+	return app.Repo.FindTenantByRegisteredNumber(apiKey.Shipper)
+}
+
+// normalizeTenant - trims the tenant to be stored (zipcodes without separators)
+func normalizeTenant(req requestTenant) requestTenant {
+	req.Name = strings.TrimSpace(req.Name)
+	req.RegisteredNumber = strings.TrimSpace(req.RegisteredNumber)
+
+	for key := range req.Origins {
+		req.Origins[key].Zipcode = normalizeZipcode(req.Origins[key].Zipcode)
+	}
+
+	return req
+}
+
+// checkTenant - verifies if a tenant has a name, numeric registered number, credentials and warehouses
+func checkTenant(req requestTenant) (args []string) {
+	args = make([]string, 0)
+
+	if req.Name == "" {
+		args = append(args, "Name is required")
+	}
+
+	if _, err := strconv.Atoi(req.RegisteredNumber); err != nil {
+		args = append(args, "Registered number is required (numeric)")
+	}
+
+	if req.Token == "" {
+		args = append(args, "Token is required")
+	}
+
+	if req.PlatformCode == "" {
+		args = append(args, "Platform code is required")
+	}
+
+	if len(req.Origins) == 0 {
+		args = append(args, "Origins are required")
+	}
+
+	for key, origin := range req.Origins {
+		if _, err := strconv.Atoi(origin.Zipcode); err != nil {
+			args = append(args, fmt.Sprintf("Zipcode is required (numeric) for Origins[%d]", key))
+		}
+	}
+
+	return args
+}
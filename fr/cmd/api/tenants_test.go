@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mtrdgs/fr/data"
+	"github.com/mtrdgs/fr/fakefr"
+)
+
+// newTenantApp - app with authentication enabled, calling the fake api, with a stored tenant and a key of it (returned along with it)
+func newTenantApp(t *testing.T, server *fakefr.Server) (*Config, string) {
+	app, _ := newAuthApp(t)
+	app.Settings.FreteRapido = freteRapidoSettings{RegisteredNumber: "25438296000158", Token: "token", PlatformCode: "platform", Zipcode: "29161376"}
+	app.Providers = []QuoteProvider{newFakeProvider(server)}
+
+	_, err := app.Repo.InsertTenant(data.Tenant{
+		Name:             "second store",
+		RegisteredNumber: "11222333000181",
+		Token:            "tenant-token",
+		PlatformCode:     "tenant-platform",
+		Origins:          []data.Origin{{Zipcode: "88010000"}},
+	})
+	if err != nil {
+		t.Fatalf("InsertTenant() error = %v", err)
+	}
+
+	key, hash, err := generateAPIKey()
+	if err != nil {
+		t.Fatalf("generateAPIKey() error = %v", err)
+	}
+	_, _ = app.Repo.InsertAPIKey(data.APIKey{Name: "second storefront", Hash: hash, Scopes: []string{data.ScopeQuoteCreate, data.ScopeQuoteRead, data.ScopeMetricsRead}, Shipper: "11222333000181"})
+
+	return app, key
+}
+
+// callWithKey - calls a route with an api key, returning the recorded response
+func callWithKey(app *Config, method, path, key string, body any) *httptest.ResponseRecorder {
+	var payload []byte
+	if body != nil {
+		payload, _ = json.Marshal(body)
+	}
+
+	req, _ := http.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("X-API-Key", key)
+	rr := httptest.NewRecorder()
+
+	app.routes().ServeHTTP(rr, req)
+	return rr
+}
+
+func TestConfig_Quote_tenant(t *testing.T) {
+	server := fakefr.NewServer(fakefr.Options{})
+	defer server.Close()
+
+	app, key := newTenantApp(t, server)
+
+	// tenant's key quotes with tenant's credentials and warehouses
+	rr := callWithKey(app, http.MethodPost, "/quote", key, validRequestQuote())
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d (%s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var quote responseQuote
+	_ = json.Unmarshal(rr.Body.Bytes(), &quote)
+
+	requests := server.Handler.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request to the api but got %d", len(requests))
+	}
+
+	shipper := requests[0].Shipper
+	if shipper.RegisteredNumber != "11222333000181" || shipper.Token != "tenant-token" || shipper.PlatformCode != "tenant-platform" {
+		t.Errorf("expected tenant's credentials but got %+v", shipper)
+	}
+
+	if dispatchers := requests[0].Dispatchers; len(dispatchers) != 1 || dispatchers[0].Zipcode != 88010000 || dispatchers[0].RegisteredNumber != "11222333000181" {
+		t.Errorf("expected tenant's warehouse but got %+v", dispatchers)
+	}
+
+	if quote.Shipper != "11222333000181" || quote.Request == nil || quote.Request.Origins[0] != "88010000" {
+		t.Errorf("expected a quote of the tenant but got shipper %s, request %+v", quote.Shipper, quote.Request)
+	}
+
+	// admin key (default tenant) quotes with settings' credentials
+	if rr := callWithKey(app, http.MethodPost, "/quote", testAdminKey, validRequestQuote()); rr.Code != http.StatusOK {
+		t.Fatalf("expected %d but got %d (%s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if requests = server.Handler.Requests(); requests[1].Shipper.Token != "token" || requests[1].Dispatchers[0].Zipcode != 29161376 {
+		t.Errorf("expected settings' credentials but got %+v", requests[1])
+	}
+
+	// quotes are partitioned by tenant
+	var quotes responseQuotes
+	rr = callWithKey(app, http.MethodGet, "/quotes", key, nil)
+	_ = json.Unmarshal(rr.Body.Bytes(), &quotes)
+
+	if len(quotes.Quotes) != 1 || quotes.Quotes[0].ID != quote.ID {
+		t.Errorf("expected only the tenant's quote but got %+v", quotes.Quotes)
+	}
+
+	if rr := callWithKey(app, http.MethodGet, "/quotes/"+quote.ID.Hex(), key, nil); rr.Code != http.StatusOK {
+		t.Errorf("expected %d getting the tenant's quote but got %d", http.StatusOK, rr.Code)
+	}
+
+	if rr := callWithKey(app, http.MethodGet, "/quotes/"+quote.ID.Hex(), testAdminKey, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected %d getting another tenant's quote but got %d", http.StatusNotFound, rr.Code)
+	}
+
+	// metrics too
+	var metrics responseMetrics
+	rr = callWithKey(app, http.MethodGet, "/metrics", key, nil)
+	_ = json.Unmarshal(rr.Body.Bytes(), &metrics)
+
+	if rr.Code != http.StatusOK || len(metrics.Metrics) != 1 || metrics.Metrics[0].ResultsPerCarrier["test"] != 0 {
+		t.Errorf("expected metrics of the tenant's quote only but got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestConfig_authenticate_tenant(t *testing.T) {
+	server := fakefr.NewServer(fakefr.Options{})
+	defer server.Close()
+
+	app, key := newTenantApp(t, server)
+
+	tenant, _ := app.Repo.FindTenantByRegisteredNumber("11222333000181")
+	if err := app.Repo.DeleteTenant(tenant.ID.Hex()); err != nil {
+		t.Fatalf("DeleteTenant() error = %v", err)
+	}
+
+	// keys of removed tenants are rejected
+	if rr := callWithKey(app, http.MethodGet, "/quotes", key, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected %d but got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestConfig_Tenants(t *testing.T) {
+	app, _ := newAuthApp(t)
+
+	tenant := requestTenant{
+		Name:             "second store",
+		RegisteredNumber: "11222333000181",
+		Token:            "tenant-token",
+		PlatformCode:     "tenant-platform",
+		Origins:          []data.Origin{{Zipcode: "88010-000"}},
+	}
+
+	// create (token is not sent back)
+	rr := callWithKey(app, http.MethodPost, "/tenants", testAdminKey, tenant)
+	if rr.Code != http.StatusCreated || strings.Contains(rr.Body.String(), "tenant-token") {
+		t.Fatalf("create tenant: expected %d without token but got %d %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	var created data.Tenant
+	_ = json.Unmarshal(rr.Body.Bytes(), &created)
+
+	if created.Origins[0].Zipcode != "88010000" {
+		t.Errorf("create tenant: expected normalized zipcode but got %+v", created.Origins)
+	}
+
+	if rr := callWithKey(app, http.MethodPost, "/tenants", testAdminKey, tenant); rr.Code != http.StatusConflict {
+		t.Errorf("create duplicated tenant: expected %d but got %d", http.StatusConflict, rr.Code)
+	}
+
+	if rr := callWithKey(app, http.MethodPost, "/tenants", testAdminKey, requestTenant{Name: "invalid"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("create invalid tenant: expected %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// list and get
+	if rr := callWithKey(app, http.MethodGet, "/tenants", testAdminKey, nil); rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "tenant-token") {
+		t.Errorf("list tenants: expected %d without tokens but got %d %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if rr := callWithKey(app, http.MethodGet, "/tenants/"+created.ID.Hex(), testAdminKey, nil); rr.Code != http.StatusOK {
+		t.Errorf("get tenant: expected %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	// update without token keeps it, registered number can not change
+	tenant.Name = "renamed store"
+	tenant.Token = ""
+	if rr := callWithKey(app, http.MethodPut, "/tenants/"+created.ID.Hex(), testAdminKey, tenant); rr.Code != http.StatusOK {
+		t.Errorf("update tenant: expected %d but got %d (%s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if stored, _ := app.Repo.FindTenantByID(created.ID.Hex()); stored.Name != "renamed store" || stored.Token != "tenant-token" {
+		t.Errorf("update tenant: expected new name and same token but got %+v", stored)
+	}
+
+	tenant.RegisteredNumber = "99888777000166"
+	if rr := callWithKey(app, http.MethodPut, "/tenants/"+created.ID.Hex(), testAdminKey, tenant); rr.Code != http.StatusBadRequest {
+		t.Errorf("update registered number: expected %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// keys of the tenant, without admin scopes
+	rr = callWithKey(app, http.MethodPost, "/keys", testAdminKey, requestAPIKeyCreate{Name: "second storefront", Scopes: []string{data.ScopeQuoteCreate}, Shipper: "11222333000181"})
+	if rr.Code != http.StatusCreated {
+		t.Errorf("issue tenant's key: expected %d but got %d (%s)", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	if rr := callWithKey(app, http.MethodPost, "/keys", testAdminKey, requestAPIKeyCreate{Name: "unknown", Scopes: []string{data.ScopeQuoteCreate}, Shipper: "99888777000166"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("issue key of unknown tenant: expected %d but got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// delete
+	if rr := callWithKey(app, http.MethodDelete, "/tenants/"+created.ID.Hex(), testAdminKey, nil); rr.Code != http.StatusNoContent {
+		t.Errorf("delete tenant: expected %d but got %d", http.StatusNoContent, rr.Code)
+	}
+
+	if rr := callWithKey(app, http.MethodGet, "/tenants/"+created.ID.Hex(), testAdminKey, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("get deleted tenant: expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestCheckTenant(t *testing.T) {
+	valid := requestTenant{Name: "store", RegisteredNumber: "11222333000181", Token: "token", PlatformCode: "platform", Origins: []data.Origin{{Zipcode: "88010000"}}}
+
+	tests := []struct {
+		name     string
+		req      requestTenant
+		wantArgs int
+	}{
+		{name: "test #1 - valid tenant", req: valid, wantArgs: 0},
+		{name: "test #2 - missing everything", req: requestTenant{}, wantArgs: 5},
+		{name: "test #3 - invalid zipcode", req: requestTenant{Name: "store", RegisteredNumber: "11222333000181", Token: "token", PlatformCode: "platform", Origins: []data.Origin{{Zipcode: "88010000"}, {Zipcode: "abc"}}}, wantArgs: 1},
+		{name: "test #4 - registered number is not numeric", req: requestTenant{Name: "store", RegisteredNumber: "11.222.333/0001-81", Token: "token", PlatformCode: "platform", Origins: []data.Origin{{Zipcode: "88010000"}}}, wantArgs: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if gotArgs := checkTenant(tt.req); len(gotArgs) != tt.wantArgs {
+				t.Errorf("checkTenant() = %v, want %d arguments", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mtrdgs/fr/fakefr"
+)
+
+// fake freterapido simulate api, to be used in offline development
+// ex.: go run ./cmd/fakefr -addr :8081 -scenario slow -delay 5s
+func main() {
+	addr := flag.String("addr", ":8081", "address to listen on")
+	scenario := flag.String("scenario", string(fakefr.ScenarioOK), "default scenario (ok, slow, bad_request, unauthorized, server_error, malformed, empty_dispatchers, zero_price)")
+	delay := flag.Duration("delay", 2*time.Second, "delay used by the slow scenario")
+	token := flag.String("token", "", "accepted shipper token (any when empty)")
+	flag.Parse()
+
+	mux := http.NewServeMux()
+	mux.Handle(fakefr.SimulatePath, fakefr.NewHandler(fakefr.Options{
+		Scenario: fakefr.Scenario(*scenario),
+		Delay:    *delay,
+		Token:    *token,
+	}))
+
+	log.Printf("Starting fake freterapido api on %s%s (scenario: %s).", *addr, fakefr.SimulatePath, *scenario)
+
+	err := http.ListenAndServe(*addr, mux)
+	if err != nil {
+		log.Panic(err)
+	}
+}
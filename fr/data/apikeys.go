@@ -0,0 +1,151 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrAPIKeyNotFound - returned when an api key does not exist, was revoked (or its id is invalid)
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// scopes - what api keys are allowed to do
+const (
+	ScopeQuoteCreate  = "quote:create" // POST /quote
+	ScopeQuoteRead    = "quote:read"   // stored quotes (and their validation)
+	ScopeMetricsRead  = "metrics:read"
+	ScopeRulesRead    = "rules:read"
+	ScopeRulesWrite   = "rules:write"
+	ScopeStatusRead   = "status:read"
+	ScopeKeysAdmin    = "keys:admin"    // issues and revokes api keys
+	ScopeTenantsAdmin = "tenants:admin" // manages tenants (and their credentials)
+)
+
+// Scopes - every known scope
+var Scopes = []string{ScopeQuoteCreate, ScopeQuoteRead, ScopeMetricsRead, ScopeRulesRead, ScopeRulesWrite, ScopeStatusRead, ScopeKeysAdmin, ScopeTenantsAdmin}
+
+// AdminScopes - scopes that act on every tenant (not allowed in keys of a tenant)
+var AdminScopes = []string{ScopeKeysAdmin, ScopeTenantsAdmin}
+
+// APIKey - key of an api client (ex.: a storefront); only its hash is stored, the key itself is shown once, when issued
+type APIKey struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name      string             `bson:"name" json:"name"`
+	Prefix    string             `bson:"prefix" json:"prefix"` // first characters of the key, to tell keys apart
+	Hash      string             `bson:"hash" json:"-"`        // sha256 of the key
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	Shipper   string             `bson:"shipper,omitempty" json:"shipper,omitempty"` // registered number of its tenant (default tenant when empty)
+	CreatedAt *time.Time         `bson:"created_at" json:"created_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// HasScope - checks if the key is allowed to do something
+func (k APIKey) HasScope(scope string) bool {
+	for _, value := range k.Scopes {
+		if value == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InsertAPIKey - stores an api key, returning it with its id
+func (q *MongoRepository) InsertAPIKey(key APIKey) (APIKey, error) {
+	collection := client.Database("fr").Collection("api_keys")
+
+	currentTime := time.Now()
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = &currentTime
+
+	_, err := collection.InsertOne(context.TODO(), key)
+	if err != nil {
+		log.Println("Error inserting into api_keys: ", err)
+		return key, err
+	}
+
+	return key, nil
+}
+
+// FindAPIKeys - gets every api key (revoked ones too, oldest first)
+func (q *MongoRepository) FindAPIKeys() (keys []APIKey, err error) {
+	collection := client.Database("fr").Collection("api_keys")
+
+	cursor, err := collection.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		log.Printf("Error retrieving api keys: %v", err)
+		return keys, err
+	}
+
+	// convert cursor into array
+	keys = make([]APIKey, 0)
+	err = cursor.All(context.TODO(), &keys)
+	if err != nil {
+		log.Printf("Error converting api keys into JSON: %v", err)
+		return keys, err
+	}
+
+	return keys, nil
+}
+
+// FindAPIKeyByHash - gets an api key that was not revoked, by the hash of the key
+func (q *MongoRepository) FindAPIKeyByHash(hash string) (key APIKey, err error) {
+	collection := client.Database("fr").Collection("api_keys")
+
+	err = collection.FindOne(context.TODO(), bson.M{"hash": hash, "revoked_at": bson.M{"$exists": false}}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return key, ErrAPIKeyNotFound
+	}
+
+	if err != nil {
+		log.Printf("Error retrieving api key: %v", err)
+		return key, err
+	}
+
+	return key, nil
+}
+
+// RevokeAPIKey - revokes an api key (kept, so it is known who had it)
+func (q *MongoRepository) RevokeAPIKey(id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrAPIKeyNotFound
+	}
+
+	collection := client.Database("fr").Collection("api_keys")
+
+	filter := bson.M{"_id": objectID, "revoked_at": bson.M{"$exists": false}}
+	result, err := collection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		log.Printf("Error revoking api key %s: %v", id, err)
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// EnsureAPIKeyIndexes - creates the unique index used to find keys by their hash
+func (q *MongoRepository) EnsureAPIKeyIndexes() error {
+	collection := client.Database("fr").Collection("api_keys")
+
+	_, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"hash": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Error creating api key indexes: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrCacheMiss - returned when a cached response does not exist (or has expired)
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheEntry - provider's response cached until its offers expire
+type CacheEntry struct {
+	Key       string    `bson:"_id" json:"key"` // hash of the provider's request
+	Body      []byte    `bson:"body" json:"body"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// FindCacheEntry - gets a cached response that has not expired yet
+func (q *MongoRepository) FindCacheEntry(key string) (entry CacheEntry, err error) {
+	collection := client.Database("fr").Collection("cache")
+
+	// expired entries may still be there (the ttl index removes them about once a minute)
+	filter := bson.M{"_id": key, "expires_at": bson.M{"$gt": time.Now()}}
+
+	err = collection.FindOne(context.TODO(), filter).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return entry, ErrCacheMiss
+	}
+
+	if err != nil {
+		log.Printf("Error retrieving cache entry %s: %v", key, err)
+		return entry, err
+	}
+
+	return entry, nil
+}
+
+// SaveCacheEntry - caches a response (replacing the previous one with the same key)
+func (q *MongoRepository) SaveCacheEntry(entry CacheEntry) error {
+	collection := client.Database("fr").Collection("cache")
+
+	entry.CreatedAt = time.Now()
+
+	_, err := collection.ReplaceOne(context.TODO(), bson.M{"_id": entry.Key}, entry, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Error saving cache entry %s: %v", entry.Key, err)
+		return err
+	}
+
+	return nil
+}
+
+// EnsureCacheIndexes - creates the ttl index that removes expired cache entries
+func (q *MongoRepository) EnsureCacheIndexes() error {
+	collection := client.Database("fr").Collection("cache")
+
+	_, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Error creating cache indexes: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"math"
+	"sort"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// percentiles are calculated from histograms, so memory is bounded by the number of bins (not of offers):
+// values up to exactBinLimit (cents or days) have a bin of their own, so they are exact, and bigger ones
+// share bins binPrecision wide (1%), each one represented by the average of its values
+const (
+	exactBinLimit = 100
+	binPrecision  = 0.01
+)
+
+// HistogramBin - offers of a group whose values (prices or deadlines) fall in the same bin
+type HistogramBin struct {
+	Bin   int64   `bson:"bin"`
+	Count int     `bson:"count"`
+	Value float64 `bson:"value"` // average of the values in the bin
+}
+
+// histogramBin - bin of a value (same as binExpression); bins grow along with values
+func histogramBin(value float64) int64 {
+	magnitude := math.Abs(value)
+	if magnitude <= exactBinLimit {
+		return int64(math.Round(value))
+	}
+
+	bin := exactBinLimit + int64(math.Ceil(math.Log(magnitude/exactBinLimit)/math.Log1p(binPrecision)))
+	if value < 0 {
+		return -bin
+	}
+
+	return bin
+}
+
+// binExpression - mongo expression with the bin of a value (same as histogramBin)
+func binExpression(field string) bson.M {
+	magnitude := bson.M{"$abs": field}
+	logBin := bson.M{"$add": []any{exactBinLimit, bson.M{"$ceil": bson.M{"$divide": []any{
+		bson.M{"$ln": bson.M{"$divide": []any{magnitude, exactBinLimit}}},
+		math.Log1p(binPrecision),
+	}}}}}
+
+	return bson.M{"$toLong": bson.M{"$cond": []any{
+		bson.M{"$lte": []any{magnitude, exactBinLimit}},
+		bson.M{"$round": []any{field, 0}},
+		bson.M{"$cond": []any{bson.M{"$lt": []any{field, 0}}, bson.M{"$multiply": []any{logBin, -1}}, logBin}},
+	}}}
+}
+
+// histogram - values counted per bin (used by the mocked repository)
+type histogram map[int64]*HistogramBin
+
+// add - counts a value in its bin
+func (h histogram) add(value float64) {
+	bin, ok := h[histogramBin(value)]
+	if !ok {
+		bin = &HistogramBin{Bin: histogramBin(value)}
+		h[bin.Bin] = bin
+	}
+
+	// running average of the bin's values
+	bin.Count++
+	bin.Value += (value - bin.Value) / float64(bin.Count)
+}
+
+// bins - bins with values, sorted
+func (h histogram) bins() []HistogramBin {
+	bins := make([]HistogramBin, 0, len(h))
+	for _, bin := range h {
+		bins = append(bins, *bin)
+	}
+
+	sort.Slice(bins, func(i, j int) bool {
+		return bins[i].Bin < bins[j].Bin
+	})
+
+	return bins
+}
+
+// percentile - p-th percentile of the values in sorted bins (linear interpolation between closest ranks)
+func percentile(bins []HistogramBin, p float64) float64 {
+	total := 0
+	for _, bin := range bins {
+		total += bin.Count
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	rank := p / 100 * float64(total-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	lowerValue, upperValue := valueAt(bins, lower), valueAt(bins, upper)
+
+	return lowerValue + (upperValue-lowerValue)*(rank-float64(lower))
+}
+
+// valueAt - value at a position of the sorted values (every value of a bin is its average)
+func valueAt(bins []HistogramBin, position int) float64 {
+	for _, bin := range bins {
+		if position < bin.Count {
+			return bin.Value
+		}
+		position -= bin.Count
+	}
+
+	return bins[len(bins)-1].Value
+}
@@ -0,0 +1,516 @@
+package data
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MetricsQuery - which quotes are aggregated, and how
+type MetricsQuery struct {
+	Shipper string      // tenant's registered number (only its quotes are aggregated)
+	Amount  int64       // last quotes (0 means all of them)
+	From    *time.Time  // created_at >= from
+	To      *time.Time  // created_at <= to
+	Bucket  string      // "", "day", "week" or "month"
+	GroupBy string      // "carrier" (default), "carrier_service", "service" or "destination_state"
+	Policy  OfferPolicy // offers left out of the stats
+}
+
+// reasons why an offer is left out of the stats
+const (
+	ExcludedZeroPrice = "zero_price" // free offers
+	ExcludedInvalid   = "invalid"    // without carrier name, or with a negative price or deadline
+)
+
+// OfferPolicy - which offers are left out of the stats (they are counted apart, as excluded offers)
+type OfferPolicy struct {
+	ExcludeZeroPrice bool
+	ExcludeInvalid   bool
+}
+
+// exclusion - why an offer is left out of the stats ("" when it is not)
+func (policy OfferPolicy) exclusion(carrier Carrier) string {
+	if policy.ExcludeInvalid && (carrier.Name == "" || carrier.Price < 0 || carrier.Deadline < 0) {
+		return ExcludedInvalid
+	}
+
+	if policy.ExcludeZeroPrice && carrier.Price == 0 {
+		return ExcludedZeroPrice
+	}
+
+	return ""
+}
+
+// exclusionExpression - mongo expression with why an offer is left out of the stats (same as exclusion)
+func (policy OfferPolicy) exclusionExpression() any {
+	branches := make([]bson.M, 0)
+	if policy.ExcludeInvalid {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$or": []any{
+				bson.M{"$eq": []any{bson.M{"$ifNull": []any{"$carrier.name", ""}}, ""}},
+				bson.M{"$lt": []any{"$carrier.price", 0}}, // missing prices (null) are lower than 0 too
+				bson.M{"$lt": []any{"$carrier.deadline", 0}},
+			}},
+			"then": ExcludedInvalid,
+		})
+	}
+
+	if policy.ExcludeZeroPrice {
+		branches = append(branches, bson.M{
+			"case": bson.M{"$eq": []any{"$carrier.price", 0}},
+			"then": ExcludedZeroPrice,
+		})
+	}
+
+	if len(branches) == 0 {
+		return ""
+	}
+
+	return bson.M{"$switch": bson.M{"branches": branches, "default": ""}}
+}
+
+// CarrierStats - aggregated offers of a group (carrier, by default) in a period, when bucketed
+// calculated price stats (average, percentiles and std dev) are kept in cents, unrounded (see RoundMoney)
+type CarrierStats struct {
+	PeriodStart *time.Time `bson:"period_start" json:"period_start,omitempty"`
+	Key         string     `bson:"key" json:"key"` // carrier, carrier + service, service or destination state
+	Count       int        `bson:"count" json:"count"`
+	Total       Money      `bson:"total" json:"total"`
+	Average     float64    `bson:"average" json:"average"`
+	Min         Money      `bson:"min" json:"min"`
+	Max         Money      `bson:"max" json:"max"`
+
+	// offers left out by the policy (not in any other stat). when every offer of the group is excluded,
+	// count is 0 and there is no price or deadline
+	ExcludedZeroPrice int `bson:"excluded_zero_price" json:"excluded_zero_price"`
+	ExcludedInvalid   int `bson:"excluded_invalid" json:"excluded_invalid"`
+
+	// dispersion of prices (cents)
+	Median float64 `bson:"-" json:"median"`
+	P90    float64 `bson:"-" json:"p90"`
+	P95    float64 `bson:"-" json:"p95"`
+	StdDev float64 `bson:"std_dev" json:"std_dev"`
+
+	// deadlines (days)
+	DeadlineAverage float64 `bson:"deadline_average" json:"deadline_average"`
+	DeadlineMin     int     `bson:"deadline_min" json:"deadline_min"`
+	DeadlineMax     int     `bson:"deadline_max" json:"deadline_max"`
+
+	// dispersion of deadlines (days)
+	DeadlineMedian float64 `bson:"-" json:"deadline_median"`
+	DeadlineP90    float64 `bson:"-" json:"deadline_p90"`
+	DeadlineP95    float64 `bson:"-" json:"deadline_p95"`
+	DeadlineStdDev float64 `bson:"deadline_std_dev" json:"deadline_std_dev"`
+
+	// histograms used to calc percentiles (dropped once they are calculated)
+	PriceBins    []HistogramBin `bson:"-" json:"-"`
+	DeadlineBins []HistogramBin `bson:"-" json:"-"`
+}
+
+// groupID - period and key of a group of offers
+type groupID struct {
+	PeriodStart time.Time // zero when not bucketed
+	Key         string
+}
+
+// id - period and key of the stats
+func (stats CarrierStats) id() groupID {
+	id := groupID{Key: stats.Key}
+	if stats.PeriodStart != nil {
+		id.PeriodStart = *stats.PeriodStart
+	}
+
+	return id
+}
+
+// histogramRow - bin of the prices or deadlines of a group, as aggregated by mongo
+type histogramRow struct {
+	PeriodStart  *time.Time `bson:"period_start"`
+	Key          string     `bson:"key"`
+	Field        string     `bson:"field"` // price or deadline
+	HistogramBin `bson:",inline"`
+}
+
+// AggregateMetrics - calcs per carrier stats inside mongo (aggregation pipeline), so quotes are not loaded in memory
+func (q *MongoRepository) AggregateMetrics(query MetricsQuery) (stats []CarrierStats, err error) {
+	collection := client.Database("fr").Collection("quotes")
+
+	// shipper's quotes in the period (the last ones, when amount is set)
+	match := periodQuery(query.From, query.To)
+	match["shipper"] = query.Shipper
+
+	// quotes without creation date are in no period
+	if _, ok := match["created_at"]; !ok && query.Bucket != "" {
+		match["created_at"] = bson.M{"$type": "date"}
+	}
+
+	offers := []bson.M{
+		{"$match": match},
+	}
+	if query.Amount > 0 {
+		offers = append(offers,
+			bson.M{"$sort": bson.M{"created_at": -1}},
+			bson.M{"$limit": query.Amount},
+		)
+	}
+
+	// one document per offer (flagged when excluded by the policy)
+	offers = append(offers,
+		bson.M{"$unwind": "$carrier"},
+		bson.M{"$addFields": bson.M{"excluded": query.Policy.exclusionExpression()}},
+	)
+
+	// offers grouped by period and carrier
+	pipeline := append(append([]bson.M(nil), offers...),
+		bson.M{"$group": bson.M{
+			"_id": bson.M{
+				"period_start": bucketExpression(query.Bucket),
+				"key":          groupExpression(query.GroupBy),
+			},
+			"count":               bson.M{"$sum": included(1)},
+			"total":               bson.M{"$sum": included("$carrier.price")},
+			"average":             bson.M{"$avg": included("$carrier.price")},
+			"min":                 bson.M{"$min": included("$carrier.price")},
+			"max":                 bson.M{"$max": included("$carrier.price")},
+			"deadline_average":    bson.M{"$avg": included("$carrier.deadline")},
+			"deadline_min":        bson.M{"$min": included("$carrier.deadline")},
+			"deadline_max":        bson.M{"$max": included("$carrier.deadline")},
+			"std_dev":             bson.M{"$stdDevPop": included("$carrier.price")},
+			"deadline_std_dev":    bson.M{"$stdDevPop": included("$carrier.deadline")},
+			"excluded_zero_price": bson.M{"$sum": excludedBecause(ExcludedZeroPrice)},
+			"excluded_invalid":    bson.M{"$sum": excludedBecause(ExcludedInvalid)},
+		}},
+		bson.M{"$project": bson.M{
+			"_id":                 0,
+			"period_start":        "$_id.period_start",
+			"key":                 "$_id.key",
+			"count":               1,
+			"total":               1,
+			"average":             1,
+			"min":                 1,
+			"max":                 1,
+			"deadline_average":    1,
+			"deadline_min":        1,
+			"deadline_max":        1,
+			"std_dev":             1,
+			"deadline_std_dev":    1,
+			"excluded_zero_price": 1,
+			"excluded_invalid":    1,
+		}},
+		bson.M{"$sort": primitive.D{{Key: "period_start", Value: 1}, {Key: "key", Value: 1}}},
+	)
+
+	cursor, err := collection.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		log.Printf("Error aggregating metrics: %v", err)
+		return stats, err
+	}
+
+	// convert cursor into array
+	stats = make([]CarrierStats, 0)
+	err = cursor.All(context.TODO(), &stats)
+	if err != nil {
+		log.Printf("Error converting metrics into JSON: %v", err)
+		return stats, err
+	}
+
+	// percentiles ($percentile is not available in mongo 4.2) come from histograms of the same offers
+	index := make(map[groupID]*CarrierStats, len(stats))
+	for key := range stats {
+		index[stats[key].id()] = &stats[key]
+	}
+
+	cursor, err = collection.Aggregate(context.TODO(), histogramPipeline(offers, query), options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		log.Printf("Error aggregating metrics' histograms: %v", err)
+		return stats, err
+	}
+	defer cursor.Close(context.TODO())
+
+	// rows are read one by one (there is one per bin)
+	for cursor.Next(context.TODO()) {
+		var row histogramRow
+		err = cursor.Decode(&row)
+		if err != nil {
+			log.Printf("Error converting metrics' histograms into JSON: %v", err)
+			return stats, err
+		}
+
+		group, ok := index[CarrierStats{PeriodStart: row.PeriodStart, Key: row.Key}.id()]
+		if !ok {
+			continue
+		}
+
+		if row.Field == "deadline" {
+			group.DeadlineBins = append(group.DeadlineBins, row.HistogramBin)
+		} else {
+			group.PriceBins = append(group.PriceBins, row.HistogramBin)
+		}
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		log.Printf("Error aggregating metrics' histograms: %v", err)
+		return stats, err
+	}
+
+	for key := range stats {
+		stats[key].calcPercentiles()
+	}
+
+	return stats, nil
+}
+
+// histogramPipeline - counts the prices and deadlines of the offers (not excluded) per group and bin
+func histogramPipeline(offers []bson.M, query MetricsQuery) []bson.M {
+	return append(append([]bson.M(nil), offers...),
+		bson.M{"$addFields": bson.M{"values": []bson.M{
+			{"field": "price", "value": included("$carrier.price")},
+			{"field": "deadline", "value": included("$carrier.deadline")},
+		}}},
+		bson.M{"$unwind": "$values"},
+		bson.M{"$match": bson.M{"values.value": bson.M{"$type": "number"}}},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{
+				"period_start": bucketExpression(query.Bucket),
+				"key":          groupExpression(query.GroupBy),
+				"field":        "$values.field",
+				"bin":          binExpression("$values.value"),
+			},
+			"count": bson.M{"$sum": 1},
+			"value": bson.M{"$avg": "$values.value"},
+		}},
+		bson.M{"$project": bson.M{
+			"_id":          0,
+			"period_start": "$_id.period_start",
+			"key":          "$_id.key",
+			"field":        "$_id.field",
+			"bin":          "$_id.bin",
+			"count":        1,
+			"value":        1,
+		}},
+		bson.M{"$sort": primitive.D{{Key: "period_start", Value: 1}, {Key: "key", Value: 1}, {Key: "field", Value: 1}, {Key: "bin", Value: 1}}},
+	)
+}
+
+// included - value of an offer for the stats, or null (ignored by $sum, $avg, $min, ...) when it is excluded
+func included(value any) bson.M {
+	return bson.M{"$cond": []any{bson.M{"$eq": []any{"$excluded", ""}}, value, nil}}
+}
+
+// excludedBecause - 1 for offers excluded for a reason, 0 otherwise (to be summed)
+func excludedBecause(reason string) bson.M {
+	return bson.M{"$cond": []any{bson.M{"$eq": []any{"$excluded", reason}}, 1, 0}}
+}
+
+// bucketExpression - mongo expression with the start of the period of created_at (same as BucketStart)
+// built from date parts, as $dateTrunc is not available in mongo 4.2
+func bucketExpression(bucket string) any {
+	switch bucket {
+	case "day":
+		return bson.M{"$dateFromParts": bson.M{
+			"year":  bson.M{"$year": "$created_at"},
+			"month": bson.M{"$month": "$created_at"},
+			"day":   bson.M{"$dayOfMonth": "$created_at"},
+		}}
+	case "week":
+		return bson.M{"$dateFromParts": bson.M{
+			"isoWeekYear":  bson.M{"$isoWeekYear": "$created_at"},
+			"isoWeek":      bson.M{"$isoWeek": "$created_at"},
+			"isoDayOfWeek": 1,
+		}}
+	case "month":
+		return bson.M{"$dateFromParts": bson.M{
+			"year":  bson.M{"$year": "$created_at"},
+			"month": bson.M{"$month": "$created_at"},
+		}}
+	default:
+		return nil
+	}
+}
+
+// groupExpression - mongo expression with the key offers are grouped by (same as groupKey)
+func groupExpression(groupBy string) any {
+	switch groupBy {
+	case "carrier_service":
+		return bson.M{"$concat": []any{"$carrier.name", " - ", "$carrier.service"}}
+	case "service":
+		return "$carrier.service"
+	case "destination_state":
+		return bson.M{"$ifNull": []any{"$request.destination.state", UnknownState}}
+	default:
+		return "$carrier.name"
+	}
+}
+
+// groupKey - key an offer is grouped by
+func groupKey(quote QuoteEntry, carrier Carrier, groupBy string) string {
+	switch groupBy {
+	case "carrier_service":
+		return carrier.Name + " - " + carrier.Service
+	case "service":
+		return carrier.Service
+	case "destination_state":
+		if quote.Request == nil || quote.Request.Destination.State == "" {
+			return UnknownState
+		}
+		return quote.Request.Destination.State
+	default:
+		return carrier.Name
+	}
+}
+
+// aggregateStats - in memory version of AggregateMetrics (same results), used by the mocked repository
+func aggregateStats(quotes []QuoteEntry, query MetricsQuery) []CarrierStats {
+	// stats of a group, along with what its dispersion is calculated from
+	type accumulator struct {
+		stats                         *CarrierStats
+		prices, deadlines             histogram
+		priceSquares, deadlineSquares float64
+	}
+
+	index := make(map[groupID]*accumulator)
+	for _, quote := range quotes {
+		var periodStart *time.Time
+		if query.Bucket != "" {
+			// quotes without creation date are in no period
+			if quote.CreatedAt == nil {
+				continue
+			}
+
+			start := BucketStart(*quote.CreatedAt, query.Bucket)
+			periodStart = &start
+		}
+
+		for _, carrier := range quote.Carrier {
+			key := CarrierStats{PeriodStart: periodStart, Key: groupKey(quote, carrier, query.GroupBy)}.id()
+
+			group, ok := index[key]
+			if !ok {
+				group = &accumulator{stats: &CarrierStats{PeriodStart: periodStart, Key: key.Key}, prices: histogram{}, deadlines: histogram{}}
+				index[key] = group
+			}
+			stats := group.stats
+
+			switch query.Policy.exclusion(carrier) {
+			case ExcludedZeroPrice:
+				stats.ExcludedZeroPrice++
+				continue
+			case ExcludedInvalid:
+				stats.ExcludedInvalid++
+				continue
+			}
+
+			// first offer of the group sets min and max (a free offer is a valid min, not an unset one)
+			if stats.Count == 0 {
+				stats.Min, stats.Max = carrier.Price, carrier.Price
+				stats.DeadlineMin, stats.DeadlineMax = carrier.Deadline, carrier.Deadline
+			}
+
+			stats.Count++
+			stats.Total += carrier.Price
+			stats.Min = min(stats.Min, carrier.Price)
+			stats.Max = max(stats.Max, carrier.Price)
+			stats.DeadlineAverage += float64(carrier.Deadline) // divided by count below
+			stats.DeadlineMin = min(stats.DeadlineMin, carrier.Deadline)
+			stats.DeadlineMax = max(stats.DeadlineMax, carrier.Deadline)
+			group.prices.add(float64(carrier.Price))
+			group.deadlines.add(float64(carrier.Deadline))
+			group.priceSquares += float64(carrier.Price) * float64(carrier.Price)
+			group.deadlineSquares += float64(carrier.Deadline) * float64(carrier.Deadline)
+		}
+	}
+
+	result := make([]CarrierStats, 0, len(index))
+	for _, group := range index {
+		stats := group.stats
+		if stats.Count > 0 {
+			stats.Average = float64(stats.Total) / float64(stats.Count)
+			stats.DeadlineAverage = stats.DeadlineAverage / float64(stats.Count)
+			stats.StdDev = stdDevPop(stats.Count, stats.Average, group.priceSquares)
+			stats.DeadlineStdDev = stdDevPop(stats.Count, stats.DeadlineAverage, group.deadlineSquares)
+		}
+		stats.PriceBins = group.prices.bins()
+		stats.DeadlineBins = group.deadlines.bins()
+		stats.calcPercentiles()
+
+		result = append(result, *stats)
+	}
+
+	// same order as mongo's (period, then key)
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.PeriodStart != nil && b.PeriodStart != nil && !a.PeriodStart.Equal(*b.PeriodStart) {
+			return a.PeriodStart.Before(*b.PeriodStart)
+		}
+
+		return a.Key < b.Key
+	})
+
+	return result
+}
+
+// calcPercentiles - calcs median, p90 and p95 of prices and deadlines from their histograms, dropping them afterwards
+func (stats *CarrierStats) calcPercentiles() {
+	for _, bins := range [][]HistogramBin{stats.PriceBins, stats.DeadlineBins} {
+		sort.Slice(bins, func(i, j int) bool {
+			return bins[i].Bin < bins[j].Bin
+		})
+	}
+
+	stats.Median = percentile(stats.PriceBins, 50)
+	stats.P90 = percentile(stats.PriceBins, 90)
+	stats.P95 = percentile(stats.PriceBins, 95)
+
+	stats.DeadlineMedian = percentile(stats.DeadlineBins, 50)
+	stats.DeadlineP90 = percentile(stats.DeadlineBins, 90)
+	stats.DeadlineP95 = percentile(stats.DeadlineBins, 95)
+
+	stats.PriceBins = nil
+	stats.DeadlineBins = nil
+}
+
+// stdDevPop - population standard deviation from the count, mean and sum of squares of values (same as mongo's $stdDevPop)
+func stdDevPop(count int, mean, squares float64) float64 {
+	if count == 0 {
+		return 0
+	}
+
+	return math.Sqrt(max(squares/float64(count)-mean*mean, 0))
+}
+
+// BucketStart - start of the period (in UTC) a moment belongs to (weeks start on monday)
+func BucketStart(moment time.Time, bucket string) time.Time {
+	moment = moment.UTC()
+	day := time.Date(moment.Year(), moment.Month(), moment.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch bucket {
+	case "week":
+		// days since monday (sunday is 0 in go)
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// BucketEnd - start of the following period
+func BucketEnd(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
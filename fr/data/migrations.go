@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"log"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MigrateMoney - converts prices stored as float (reais), before money was kept in cents, into int64 cents
+// runs inside mongo (update with aggregation pipeline, mongo 4.2+) and only touches quotes still using floats,
+// so it is safe to run on every start up. returns how many quotes were migrated
+func (q *MongoRepository) MigrateMoney() (int64, error) {
+	collection := client.Database("fr").Collection("quotes")
+
+	// quotes with any float price (or declared value)
+	filter := bson.M{"$or": []bson.M{
+		{"carrier.price": bson.M{"$type": "double"}},
+		{"request.declared_value": bson.M{"$type": "double"}},
+	}}
+
+	update := []bson.M{
+		{"$set": bson.M{
+			"carrier": bson.M{"$map": bson.M{
+				"input": "$carrier",
+				"as":    "offer",
+				"in": bson.M{"$mergeObjects": []any{
+					"$$offer",
+					bson.M{"price": toCents("$$offer.price")},
+				}},
+			}},
+			// a missing request stays missing
+			"request": bson.M{"$cond": []any{
+				bson.M{"$eq": []any{bson.M{"$type": "$request.declared_value"}, "double"}},
+				bson.M{"$mergeObjects": []any{
+					"$request",
+					bson.M{"declared_value": toCents("$request.declared_value")},
+				}},
+				"$request",
+			}},
+		}},
+	}
+
+	result, err := collection.UpdateMany(context.TODO(), filter, update)
+	if err != nil {
+		log.Printf("Error migrating prices to cents: %v", err)
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// toCents - mongo expression converting a float value (reais) into int64 cents, keeping values already converted
+func toCents(field string) bson.M {
+	return bson.M{"$cond": []any{
+		bson.M{"$eq": []any{bson.M{"$type": field}, "double"}},
+		bson.M{"$toLong": bson.M{"$round": []any{bson.M{"$multiply": []any{field, 100}}, 0}}},
+		field,
+	}}
+}
+
+// MigrateShipper - assigns quotes stored before quotes were partitioned by tenant to a shipper (the default one)
+// only touches quotes without shipper, so it is safe to run on every start up. returns how many quotes were migrated
+func (q *MongoRepository) MigrateShipper(shipper string) (int64, error) {
+	collection := client.Database("fr").Collection("quotes")
+
+	filter := bson.M{"shipper": bson.M{"$exists": false}}
+	result, err := collection.UpdateMany(context.TODO(), filter, bson.M{"$set": bson.M{"shipper": shipper}})
+	if err != nil {
+		log.Printf("Error assigning quotes to shipper: %v", err)
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
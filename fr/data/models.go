@@ -2,9 +2,12 @@ package data
 
 import (
 	"context"
+	"errors"
 	"log"
+	"regexp"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"gopkg.in/mgo.v2/bson"
@@ -12,6 +15,12 @@ import (
 
 var client *mongo.Client
 
+// ErrNotFound - returned when a quote does not exist (or its id is invalid)
+var ErrNotFound = errors.New("quote not found")
+
+// ErrInvalidCursor - returned when a pagination cursor is not a valid quote id
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type MongoRepository struct {
 	Conn *mongo.Client
 }
@@ -26,60 +35,205 @@ func NewMongoRepository(mongo *mongo.Client) *MongoRepository {
 
 // QuoteEntry - struct to be used in bd (insert and find)
 type QuoteEntry struct {
-	Carrier   []Carrier  `bson:"carrier" json:"carrier"`
-	CreatedAt *time.Time `bson:"created_at" json:"created_at,omitempty"`
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Shipper   string             `bson:"shipper" json:"shipper"` // registered number of the tenant that requested it
+	Carrier   []Carrier          `bson:"carrier" json:"carrier"`
+	Request   *QuoteRequest      `bson:"request,omitempty" json:"request,omitempty"`
+	CreatedAt *time.Time         `bson:"created_at" json:"created_at,omitempty"`
+}
+
+// QuoteRequest - normalized request that produced a quote (used by metrics and audits)
+type QuoteRequest struct {
+	Destination   Destination `bson:"destination" json:"destination"`
+	Origins       []string    `bson:"origins" json:"origins"`
+	Volumes       []Volume    `bson:"volumes" json:"volumes"`
+	TotalWeight   float64     `bson:"total_weight" json:"total_weight"`     // kg
+	CubicVolume   float64     `bson:"cubic_volume" json:"cubic_volume"`     // m³
+	DeclaredValue Money       `bson:"declared_value" json:"declared_value"` // sum of volumes' prices
+}
+
+// UnknownState - state of destinations whose zipcode is not known (or stored before states were)
+const UnknownState = "unknown"
+
+// Destination - where volumes are shipped to
+type Destination struct {
+	Zipcode string `bson:"zipcode" json:"zipcode"`
+	State   string `bson:"state" json:"state"` // ex.: SP
+}
+
+// Volume - a volume (package) as requested
+type Volume struct {
+	Category      int     `bson:"category" json:"category"`
+	Amount        int     `bson:"amount" json:"amount"`
+	UnitaryWeight int     `bson:"unitary_weight" json:"unitary_weight"`
+	Price         int     `bson:"price" json:"price"`
+	Sku           string  `bson:"sku" json:"sku"`
+	Height        float64 `bson:"height" json:"height"`
+	Width         float64 `bson:"width" json:"width"`
+	Length        float64 `bson:"length" json:"length"`
 }
 
 // Carrier - struct to be used in bd (insert and find)
 type Carrier struct {
-	Name     string  `bson:"name" json:"name"`
-	Service  string  `bson:"service" json:"service"`
-	Deadline int     `bson:"deadline" json:"deadline"`
-	Price    float64 `bson:"price" json:"price"`
+	Name     string `bson:"name" json:"name"`
+	Service  string `bson:"service" json:"service"`
+	Deadline int    `bson:"deadline" json:"deadline"`
+	Price    Money  `bson:"price" json:"price"`
+	Provider string `bson:"provider" json:"provider"`
+	Origin   string `bson:"origin" json:"origin,omitempty"`
+
+	// until when the price is honored by the carrier (nil when the provider does not tell)
+	Expiration *time.Time `bson:"expiration,omitempty" json:"expiration,omitempty"`
+
+	// details of the offer, when the provider tells them
+	RegisteredNumber string   `bson:"registered_number,omitempty" json:"registered_number,omitempty"` // carrier's cnpj
+	CompanyName      string   `bson:"company_name,omitempty" json:"company_name,omitempty"`
+	Logo             string   `bson:"logo,omitempty" json:"logo,omitempty"`
+	TableReference   string   `bson:"table_reference,omitempty" json:"table_reference,omitempty"`
+	Weights          *Weights `bson:"weights,omitempty" json:"weights,omitempty"`
+	HomeDelivery     bool     `bson:"home_delivery,omitempty" json:"home_delivery,omitempty"`
+	EstimatedDate    string   `bson:"estimated_date,omitempty" json:"estimated_date,omitempty"` // YYYY-MM-DD
+
+	// what the carrier charges, and what is left of the price after it (price minus cost)
+	CostPrice *Money `bson:"cost_price,omitempty" json:"cost_price,omitempty"`
+	Margin    *Money `bson:"margin,omitempty" json:"margin,omitempty"`
+
+	// shipper's rules that changed the offer, and its price before them (only when it changed)
+	Rules     []AppliedRule `bson:"rules,omitempty" json:"rules,omitempty"`
+	BasePrice *Money        `bson:"base_price,omitempty" json:"base_price,omitempty"`
+}
+
+// Weights - weights (kg) considered by the carrier, which charges the used one
+type Weights struct {
+	Real  float64 `bson:"real" json:"real"`
+	Cubed float64 `bson:"cubed" json:"cubed"`
+	Used  float64 `bson:"used" json:"used"`
 }
 
-// Insert - stores quotes from freterapido api in bd
-func (q *MongoRepository) Insert(entry QuoteEntry) error {
+// SetMargin - updates the margin from the current price, when the cost is known
+func (c *Carrier) SetMargin() {
+	if c.CostPrice == nil {
+		return
+	}
+
+	margin := c.Price - *c.CostPrice
+	c.Margin = &margin
+}
+
+// QuoteFilter - criteria used to list stored quotes
+type QuoteFilter struct {
+	Shipper string     // tenant's registered number (quotes of other tenants are never listed)
+	From    *time.Time // created_at >= from
+	To      *time.Time // created_at <= to
+	Zipcode string     // destination zipcode
+	Carrier string     // carrier name (case insensitive)
+	Cursor  string     // id of the last quote of the previous page
+	Limit   int64
+}
+
+// Insert - stores quotes from freterapido api in bd, returning the stored entry (with its id)
+func (q *MongoRepository) Insert(entry QuoteEntry) (QuoteEntry, error) {
 	collection := client.Database("fr").Collection("quotes")
 
 	// little hack to trigger omitempty in json
 	// this way created_at accepts nil as value
 	currentTime := time.Now()
 	entry.CreatedAt = &currentTime
+	entry.ID = primitive.NewObjectID()
 
 	// save
 	_, err := collection.InsertOne(context.TODO(), entry)
 	if err != nil {
 		log.Println("Error inserting into quotes: ", err)
-		return err
+		return entry, err
 	}
 
-	return nil
+	return entry, nil
 }
 
-// FindSpecific - gets list of quotes from db
-func (q *MongoRepository) FindSpecific(amount int64) (quotes []QuoteEntry, err error) {
-	var cursor *mongo.Cursor
+// FindByID - gets a single quote of a shipper from db
+func (q *MongoRepository) FindByID(shipper, id string) (quote QuoteEntry, err error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return quote, ErrNotFound
+	}
 
 	collection := client.Database("fr").Collection("quotes")
 
-	if amount > 0 {
-		cursor, err = collection.Find(context.TODO(), bson.M{}, options.Find().SetLimit(amount).SetSort(bson.M{"created_at": -1}))
-	} else {
-		cursor, err = collection.Find(context.TODO(), bson.M{})
+	err = collection.FindOne(context.TODO(), bson.M{"_id": objectID, "shipper": shipper}).Decode(&quote)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return quote, ErrNotFound
 	}
 
+	if err != nil {
+		log.Printf("Error retrieving quote %s: %v", id, err)
+		return quote, err
+	}
+
+	return quote, nil
+}
+
+// FindAll - gets a page of quotes from db (newest first) matching the filter
+func (q *MongoRepository) FindAll(filter QuoteFilter) (quotes []QuoteEntry, err error) {
+	// shipper and period
+	query := periodQuery(filter.From, filter.To)
+	query["shipper"] = filter.Shipper
+
+	// destination and carrier
+	if filter.Zipcode != "" {
+		query["request.destination.zipcode"] = filter.Zipcode
+	}
+	if filter.Carrier != "" {
+		query["carrier.name"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(filter.Carrier) + "$", Options: "i"}
+	}
+
+	// cursor (ids grow over time, so older quotes have smaller ids)
+	if filter.Cursor != "" {
+		cursorID, err := primitive.ObjectIDFromHex(filter.Cursor)
+		if err != nil {
+			return quotes, ErrInvalidCursor
+		}
+		query["_id"] = bson.M{"$lt": cursorID}
+	}
+
+	collection := client.Database("fr").Collection("quotes")
+
+	opts := options.Find().SetSort(bson.M{"_id": -1})
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := collection.Find(context.TODO(), query, opts)
 	if err != nil {
 		log.Printf("Error retrieving quotes: %v", err)
 		return quotes, err
 	}
 
 	// convert cursor into array
+	quotes = make([]QuoteEntry, 0)
 	err = cursor.All(context.TODO(), &quotes)
 	if err != nil {
 		log.Printf("Error converting quotes into JSON: %v", err)
 		return quotes, err
 	}
 
-	return quotes, err
+	return quotes, nil
+}
+
+// periodQuery - filter on created_at (from and to are inclusive, nil means unbounded)
+func periodQuery(from, to *time.Time) bson.M {
+	query := bson.M{}
+
+	createdAt := bson.M{}
+	if from != nil {
+		createdAt["$gte"] = *from
+	}
+	if to != nil {
+		createdAt["$lte"] = *to
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	return query
 }
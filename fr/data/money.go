@@ -0,0 +1,95 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Money - monetary value (BRL) in integer cents, so sums and comparisons have no rounding drift
+// it is stored in mongo as int64 and sent/received in json as a decimal number (ex.: 93.35)
+type Money int64
+
+// RoundMoney - converts a value in cents (ex.: an average) into money, rounding half away from zero
+// should only be used when presenting calculated values
+func RoundMoney(cents float64) Money {
+	return Money(math.Round(cents))
+}
+
+// ParseMoney - parses a decimal (ex.: "93.35", "1e2"), rounding half away from zero to cents
+func ParseMoney(value string) (Money, error) {
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return 0, fmt.Errorf("invalid money value %q", value)
+	}
+
+	// value in cents = num * 100 / denom
+	num := new(big.Int).Mul(rat.Num(), big.NewInt(100))
+	quo, rem := new(big.Int).QuoRem(num, rat.Denom(), new(big.Int))
+
+	// half away from zero
+	rem.Abs(rem).Mul(rem, big.NewInt(2))
+	if rem.Cmp(rat.Denom()) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("money value %q out of range", value)
+	}
+
+	return Money(quo.Int64()), nil
+}
+
+// Float64 - value in reais (only for presentation/calculations that do not need to be exact)
+func (m Money) Float64() float64 {
+	return float64(m) / 100
+}
+
+// String - value in reais, with 2 decimal places (ex.: "93.35")
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON - sends money as a decimal json number (ex.: 93.35)
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON - reads money from a json number (or a numeric string) without going through float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	value := string(data)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+
+	parsed, err := ParseMoney(value)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Percent - percentage of the value (ex.: 10 for 10%), rounded half away from zero to cents
+func (m Money) Percent(percentage float64) Money {
+	return RoundMoney(float64(m) * percentage / 100)
+}
@@ -2,6 +2,25 @@ package data
 
 // RepositoryPattern - interface to be used as repository
 type RepositoryPattern interface {
-	Insert(entry QuoteEntry) error
-	FindSpecific(amount int64) (quotes []QuoteEntry, err error)
+	Insert(entry QuoteEntry) (QuoteEntry, error)
+	FindByID(shipper, id string) (quote QuoteEntry, err error)
+	FindAll(filter QuoteFilter) (quotes []QuoteEntry, err error)
+	AggregateMetrics(query MetricsQuery) (stats []CarrierStats, err error)
+	InsertRule(rule Rule) (Rule, error)
+	FindRules(shipper string) (rules []Rule, err error)
+	FindRuleByID(shipper, id string) (rule Rule, err error)
+	UpdateRule(rule Rule) (Rule, error)
+	DeleteRule(shipper, id string) error
+	FindCacheEntry(key string) (entry CacheEntry, err error)
+	SaveCacheEntry(entry CacheEntry) error
+	InsertAPIKey(key APIKey) (APIKey, error)
+	FindAPIKeys() (keys []APIKey, err error)
+	FindAPIKeyByHash(hash string) (key APIKey, err error)
+	RevokeAPIKey(id string) error
+	InsertTenant(tenant Tenant) (Tenant, error)
+	FindTenants() (tenants []Tenant, err error)
+	FindTenantByID(id string) (tenant Tenant, err error)
+	FindTenantByRegisteredNumber(registeredNumber string) (tenant Tenant, err error)
+	UpdateTenant(tenant Tenant) (Tenant, error)
+	DeleteTenant(id string) error
 }
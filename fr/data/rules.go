@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrRuleNotFound - returned when a rule does not exist (or its id is invalid)
+var ErrRuleNotFound = errors.New("rule not found")
+
+// types of rules
+const (
+	RuleHideCarrier      = "hide_carrier"      // hides offers from carriers
+	RuleMaxDeadline      = "max_deadline"      // hides offers slower than max_deadline
+	RuleMarkupPercentage = "markup_percentage" // adds a percentage to prices
+	RuleMarkupFixed      = "markup_fixed"      // adds an amount to prices
+	RuleStateDiscount    = "state_discount"    // takes a percentage off prices to some destination states
+	RuleFreeShipping     = "free_shipping"     // zeroes prices when the cart (declared value) reaches min_cart_value
+)
+
+// Rule - shipper's business rule applied to offers before they are shown (and stored)
+type Rule struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Shipper      string             `bson:"shipper" json:"shipper"` // shipper's registered number
+	Name         string             `bson:"name" json:"name"`
+	Type         string             `bson:"type" json:"type"`
+	Carriers     []string           `bson:"carriers" json:"carriers"`             // carriers it applies to (all, when empty; required to hide)
+	States       []string           `bson:"states" json:"states"`                 // destination states (state_discount)
+	MaxDeadline  int                `bson:"max_deadline" json:"max_deadline"`     // days (max_deadline)
+	Percentage   float64            `bson:"percentage" json:"percentage"`         // markup_percentage and state_discount (ex.: 10 means 10%)
+	Amount       Money              `bson:"amount" json:"amount"`                 // markup_fixed
+	MinCartValue Money              `bson:"min_cart_value" json:"min_cart_value"` // free_shipping
+	Priority     int                `bson:"priority" json:"priority"`             // lower first, among rules of the same type
+	Disabled     bool               `bson:"disabled" json:"disabled"`
+	CreatedAt    *time.Time         `bson:"created_at" json:"created_at,omitempty"`
+	UpdatedAt    *time.Time         `bson:"updated_at" json:"updated_at,omitempty"`
+}
+
+// AppliedRule - rule that changed an offer
+type AppliedRule struct {
+	ID   string `bson:"id" json:"id"`
+	Name string `bson:"name" json:"name"`
+	Type string `bson:"type" json:"type"`
+}
+
+// InsertRule - stores a shipper's rule, returning it with its id
+func (q *MongoRepository) InsertRule(rule Rule) (Rule, error) {
+	collection := client.Database("fr").Collection("rules")
+
+	currentTime := time.Now()
+	rule.ID = primitive.NewObjectID()
+	rule.CreatedAt = &currentTime
+	rule.UpdatedAt = &currentTime
+
+	_, err := collection.InsertOne(context.TODO(), rule)
+	if err != nil {
+		log.Println("Error inserting into rules: ", err)
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// FindRules - gets every rule of a shipper (by priority, then oldest first)
+func (q *MongoRepository) FindRules(shipper string) (rules []Rule, err error) {
+	collection := client.Database("fr").Collection("rules")
+
+	opts := options.Find().SetSort(primitive.D{{Key: "priority", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := collection.Find(context.TODO(), bson.M{"shipper": shipper}, opts)
+	if err != nil {
+		log.Printf("Error retrieving rules: %v", err)
+		return rules, err
+	}
+
+	// convert cursor into array
+	rules = make([]Rule, 0)
+	err = cursor.All(context.TODO(), &rules)
+	if err != nil {
+		log.Printf("Error converting rules into JSON: %v", err)
+		return rules, err
+	}
+
+	return rules, nil
+}
+
+// FindRuleByID - gets a single rule of a shipper
+func (q *MongoRepository) FindRuleByID(shipper, id string) (rule Rule, err error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return rule, ErrRuleNotFound
+	}
+
+	collection := client.Database("fr").Collection("rules")
+
+	err = collection.FindOne(context.TODO(), bson.M{"_id": objectID, "shipper": shipper}).Decode(&rule)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return rule, ErrRuleNotFound
+	}
+
+	if err != nil {
+		log.Printf("Error retrieving rule %s: %v", id, err)
+		return rule, err
+	}
+
+	return rule, nil
+}
+
+// UpdateRule - replaces a shipper's rule (keeping when it was created), returning the stored rule
+func (q *MongoRepository) UpdateRule(rule Rule) (Rule, error) {
+	collection := client.Database("fr").Collection("rules")
+
+	current, err := q.FindRuleByID(rule.Shipper, rule.ID.Hex())
+	if err != nil {
+		return rule, err
+	}
+
+	currentTime := time.Now()
+	rule.CreatedAt = current.CreatedAt
+	rule.UpdatedAt = &currentTime
+
+	result, err := collection.ReplaceOne(context.TODO(), bson.M{"_id": rule.ID, "shipper": rule.Shipper}, rule)
+	if err != nil {
+		log.Printf("Error updating rule %s: %v", rule.ID.Hex(), err)
+		return rule, err
+	}
+
+	if result.MatchedCount == 0 {
+		return rule, ErrRuleNotFound
+	}
+
+	return rule, nil
+}
+
+// DeleteRule - removes a shipper's rule
+func (q *MongoRepository) DeleteRule(shipper, id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrRuleNotFound
+	}
+
+	collection := client.Database("fr").Collection("rules")
+
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": objectID, "shipper": shipper})
+	if err != nil {
+		log.Printf("Error deleting rule %s: %v", id, err)
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrRuleNotFound
+	}
+
+	return nil
+}
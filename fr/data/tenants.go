@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ErrTenantNotFound - returned when a tenant does not exist (or its id is invalid)
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// ErrTenantExists - returned when a tenant with the same registered number is already stored
+var ErrTenantExists = errors.New("tenant already exists")
+
+// Tenant - a shipper (store) served by the api, with its own freterapido credentials and warehouses
+// quotes, rules and metrics belong to its registered number
+type Tenant struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	RegisteredNumber string             `bson:"registered_number" json:"registered_number"` // shipper's cnpj
+	Token            string             `bson:"token" json:"-"`                             // never sent back to clients
+	PlatformCode     string             `bson:"platform_code" json:"platform_code"`
+	Origins          []Origin           `bson:"origins" json:"origins"`
+	CreatedAt        *time.Time         `bson:"created_at" json:"created_at,omitempty"`
+	UpdatedAt        *time.Time         `bson:"updated_at" json:"updated_at,omitempty"`
+}
+
+// Origin - a warehouse (dispatcher) of a tenant
+type Origin struct {
+	Zipcode          string `bson:"zipcode" json:"zipcode"`
+	RegisteredNumber string `bson:"registered_number,omitempty" json:"registered_number,omitempty"` // default: tenant's registered number
+}
+
+// InsertTenant - stores a tenant, returning it with its id
+func (q *MongoRepository) InsertTenant(tenant Tenant) (Tenant, error) {
+	collection := client.Database("fr").Collection("tenants")
+
+	currentTime := time.Now()
+	tenant.ID = primitive.NewObjectID()
+	tenant.CreatedAt = &currentTime
+	tenant.UpdatedAt = &currentTime
+
+	_, err := collection.InsertOne(context.TODO(), tenant)
+	if mongo.IsDuplicateKeyError(err) {
+		return tenant, ErrTenantExists
+	}
+
+	if err != nil {
+		log.Println("Error inserting into tenants: ", err)
+		return tenant, err
+	}
+
+	return tenant, nil
+}
+
+// FindTenants - gets every tenant (oldest first)
+func (q *MongoRepository) FindTenants() (tenants []Tenant, err error) {
+	collection := client.Database("fr").Collection("tenants")
+
+	cursor, err := collection.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		log.Printf("Error retrieving tenants: %v", err)
+		return tenants, err
+	}
+
+	// convert cursor into array
+	tenants = make([]Tenant, 0)
+	err = cursor.All(context.TODO(), &tenants)
+	if err != nil {
+		log.Printf("Error converting tenants into JSON: %v", err)
+		return tenants, err
+	}
+
+	return tenants, nil
+}
+
+// FindTenantByID - gets a single tenant
+func (q *MongoRepository) FindTenantByID(id string) (tenant Tenant, err error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return tenant, ErrTenantNotFound
+	}
+
+	return q.findTenant(bson.M{"_id": objectID})
+}
+
+// FindTenantByRegisteredNumber - gets the tenant of a shipper (used to resolve the tenant of api keys)
+func (q *MongoRepository) FindTenantByRegisteredNumber(registeredNumber string) (tenant Tenant, err error) {
+	return q.findTenant(bson.M{"registered_number": registeredNumber})
+}
+
+// findTenant - gets the tenant matching a filter
+func (q *MongoRepository) findTenant(filter bson.M) (tenant Tenant, err error) {
+	collection := client.Database("fr").Collection("tenants")
+
+	err = collection.FindOne(context.TODO(), filter).Decode(&tenant)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return tenant, ErrTenantNotFound
+	}
+
+	if err != nil {
+		log.Printf("Error retrieving tenant: %v", err)
+		return tenant, err
+	}
+
+	return tenant, nil
+}
+
+// UpdateTenant - replaces a tenant (its registered number and creation date are kept)
+func (q *MongoRepository) UpdateTenant(tenant Tenant) (Tenant, error) {
+	collection := client.Database("fr").Collection("tenants")
+
+	currentTime := time.Now()
+	update := bson.M{"$set": bson.M{
+		"name":          tenant.Name,
+		"token":         tenant.Token,
+		"platform_code": tenant.PlatformCode,
+		"origins":       tenant.Origins,
+		"updated_at":    currentTime,
+	}}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(context.TODO(), bson.M{"_id": tenant.ID}, update, opts).Decode(&tenant)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return tenant, ErrTenantNotFound
+	}
+
+	if err != nil {
+		log.Printf("Error updating tenant %s: %v", tenant.ID.Hex(), err)
+		return tenant, err
+	}
+
+	return tenant, nil
+}
+
+// DeleteTenant - removes a tenant (its quotes and rules are kept)
+func (q *MongoRepository) DeleteTenant(id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrTenantNotFound
+	}
+
+	collection := client.Database("fr").Collection("tenants")
+
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": objectID})
+	if err != nil {
+		log.Printf("Error deleting tenant %s: %v", id, err)
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrTenantNotFound
+	}
+
+	return nil
+}
+
+// EnsureTenantIndexes - creates the unique index of tenants' registered numbers, and the one used to list a shipper's quotes
+func (q *MongoRepository) EnsureTenantIndexes() error {
+	_, err := client.Database("fr").Collection("tenants").Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.M{"registered_number": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Error creating tenant indexes: %v", err)
+		return err
+	}
+
+	_, err = client.Database("fr").Collection("quotes").Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: primitive.D{{Key: "shipper", Value: 1}, {Key: "_id", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Error creating quote indexes: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -1,43 +1,423 @@
 package data
 
 import (
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type MongoTestRepository struct {
 	Conn *mongo.Client
+
+	mu      sync.Mutex
+	quotes  []QuoteEntry
+	rules   []Rule
+	cache   map[string]CacheEntry
+	keys    []APIKey
+	tenants []Tenant
 }
 
 // NewMongoTetRepository - mocked repository to be used in tests
 func NewMongoTestRepository(mongo *mongo.Client) *MongoTestRepository {
+	currentTime := time.Now()
+
 	return &MongoTestRepository{
 		Conn: mongo,
+		quotes: []QuoteEntry{
+			{
+				ID: primitive.NewObjectID(),
+				Carrier: []Carrier{
+					{
+						Name:     "test",
+						Service:  "test",
+						Deadline: 1,
+						Price:    150,
+					},
+				},
+				CreatedAt: &currentTime,
+			},
+		},
+	}
+}
+
+// Insert - mocked insert function to be used in tests (keeps the entry in memory)
+func (q *MongoTestRepository) Insert(entry QuoteEntry) (QuoteEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// created_at can be set by tests
+	if entry.CreatedAt == nil {
+		currentTime := time.Now()
+		entry.CreatedAt = &currentTime
+	}
+	entry.ID = primitive.NewObjectID()
+
+	q.quotes = append(q.quotes, entry)
+
+	return entry, nil
+}
+
+// FindByID - mocked find function to be used in tests
+func (q *MongoTestRepository) FindByID(shipper, id string) (quote QuoteEntry, err error) {
+	for _, quote := range q.newestFirst() {
+		if quote.ID.Hex() == id && quote.Shipper == shipper {
+			return quote, nil
+		}
+	}
+
+	return quote, ErrNotFound
+}
+
+// FindAll - mocked find function to be used in tests (same filters as mongo's)
+func (q *MongoTestRepository) FindAll(filter QuoteFilter) (quotes []QuoteEntry, err error) {
+	var cursorID primitive.ObjectID
+	if filter.Cursor != "" {
+		cursorID, err = primitive.ObjectIDFromHex(filter.Cursor)
+		if err != nil {
+			return quotes, ErrInvalidCursor
+		}
+	}
+
+	quotes = make([]QuoteEntry, 0)
+	for _, quote := range q.newestFirst() {
+		switch {
+		case quote.Shipper != filter.Shipper:
+			continue
+		case !inPeriod(quote, filter.From, filter.To):
+			continue
+		case filter.Zipcode != "" && (quote.Request == nil || quote.Request.Destination.Zipcode != filter.Zipcode):
+			continue
+		case filter.Carrier != "" && !hasCarrier(quote, filter.Carrier):
+			continue
+		case filter.Cursor != "" && quote.ID.Hex() >= cursorID.Hex():
+			continue
+		}
+
+		quotes = append(quotes, quote)
+		if filter.Limit > 0 && int64(len(quotes)) == filter.Limit {
+			break
+		}
+	}
+
+	return quotes, nil
+}
+
+// AggregateMetrics - mocked aggregation (in memory, same results as mongo's pipeline) to be used in tests
+func (q *MongoTestRepository) AggregateMetrics(query MetricsQuery) (stats []CarrierStats, err error) {
+	quotes, err := q.FindAll(QuoteFilter{Shipper: query.Shipper, From: query.From, To: query.To})
+	if err != nil {
+		return stats, err
+	}
+
+	return aggregateStats(lastQuotes(quotes, query.Amount), query), nil
+}
+
+// InsertRule - mocked insert function to be used in tests (keeps the rule in memory)
+func (q *MongoTestRepository) InsertRule(rule Rule) (Rule, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	currentTime := time.Now()
+	rule.ID = primitive.NewObjectID()
+	rule.CreatedAt = &currentTime
+	rule.UpdatedAt = &currentTime
+
+	q.rules = append(q.rules, rule)
+
+	return rule, nil
+}
+
+// FindRules - mocked find function to be used in tests (same order as mongo's)
+func (q *MongoTestRepository) FindRules(shipper string) (rules []Rule, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	rules = make([]Rule, 0)
+	for _, rule := range q.rules {
+		if rule.Shipper == shipper {
+			rules = append(rules, rule)
+		}
 	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority < rules[j].Priority
+		}
+
+		return rules[i].ID.Hex() < rules[j].ID.Hex()
+	})
+
+	return rules, nil
 }
 
-// Insert - mocked insert function to be used in tests
-func (q *MongoTestRepository) Insert(entry QuoteEntry) error {
+// FindRuleByID - mocked find function to be used in tests
+func (q *MongoTestRepository) FindRuleByID(shipper, id string) (rule Rule, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, rule := range q.rules {
+		if rule.ID.Hex() == id && rule.Shipper == shipper {
+			return rule, nil
+		}
+	}
+
+	return rule, ErrRuleNotFound
+}
+
+// UpdateRule - mocked update function to be used in tests
+func (q *MongoTestRepository) UpdateRule(rule Rule) (Rule, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, current := range q.rules {
+		if current.ID == rule.ID && current.Shipper == rule.Shipper {
+			currentTime := time.Now()
+			rule.CreatedAt = current.CreatedAt
+			rule.UpdatedAt = &currentTime
+
+			q.rules[key] = rule
+			return rule, nil
+		}
+	}
+
+	return rule, ErrRuleNotFound
+}
+
+// DeleteRule - mocked delete function to be used in tests
+func (q *MongoTestRepository) DeleteRule(shipper, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, rule := range q.rules {
+		if rule.ID.Hex() == id && rule.Shipper == shipper {
+			q.rules = append(q.rules[:key], q.rules[key+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRuleNotFound
+}
+
+// FindCacheEntry - mocked find function to be used in tests (only entries that have not expired)
+func (q *MongoTestRepository) FindCacheEntry(key string) (entry CacheEntry, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.cache[key]
+	if !ok || !entry.ExpiresAt.After(time.Now()) {
+		return CacheEntry{}, ErrCacheMiss
+	}
+
+	return entry, nil
+}
+
+// SaveCacheEntry - mocked save function to be used in tests (keeps the entry in memory)
+func (q *MongoTestRepository) SaveCacheEntry(entry CacheEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.cache == nil {
+		q.cache = make(map[string]CacheEntry)
+	}
+
+	entry.CreatedAt = time.Now()
+	q.cache[entry.Key] = entry
+
 	return nil
 }
 
-// FindSpecific - mocked find function to be used in tests
-func (q *MongoTestRepository) FindSpecific(amount int64) (quotes []QuoteEntry, err error) {
+// newestFirst - copy of stored quotes, sorted by id (newest first)
+func (q *MongoTestRepository) newestFirst() []QuoteEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quotes := append([]QuoteEntry(nil), q.quotes...)
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].ID.Hex() > quotes[j].ID.Hex()
+	})
+
+	return quotes
+}
+
+// inPeriod - checks if a quote was created in a period (from and to are inclusive, nil means unbounded)
+func inPeriod(quote QuoteEntry, from, to *time.Time) bool {
+	return (from == nil || !quote.CreatedAt.Before(*from)) && (to == nil || !quote.CreatedAt.After(*to))
+}
+
+// lastQuotes - newest quotes (by creation, as mongo's), limited to amount when it is set
+func lastQuotes(quotes []QuoteEntry, amount int64) []QuoteEntry {
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].CreatedAt.After(*quotes[j].CreatedAt)
+	})
+
+	if amount > 0 && int64(len(quotes)) > amount {
+		quotes = quotes[:amount]
+	}
+
+	return quotes
+}
+
+// hasCarrier - checks if a quote has an offer from a carrier (case insensitive)
+func hasCarrier(quote QuoteEntry, name string) bool {
+	for _, carrier := range quote.Carrier {
+		if strings.EqualFold(carrier.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InsertAPIKey - mocked insert function to be used in tests (keeps the key in memory)
+func (q *MongoTestRepository) InsertAPIKey(key APIKey) (APIKey, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	currentTime := time.Now()
-	quotes = []QuoteEntry{
-		{
-			Carrier: []Carrier{
-				{
-					Name:     "test",
-					Service:  "test",
-					Deadline: 1,
-					Price:    1.5,
-				},
-			},
-			CreatedAt: &currentTime,
-		},
+	key.ID = primitive.NewObjectID()
+	key.CreatedAt = &currentTime
+
+	q.keys = append(q.keys, key)
+
+	return key, nil
+}
+
+// FindAPIKeys - mocked find function to be used in tests
+func (q *MongoTestRepository) FindAPIKeys() (keys []APIKey, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keys = make([]APIKey, 0, len(q.keys))
+	keys = append(keys, q.keys...)
+
+	return keys, nil
+}
+
+// FindAPIKeyByHash - mocked find function to be used in tests (only keys that were not revoked)
+func (q *MongoTestRepository) FindAPIKeyByHash(hash string) (key APIKey, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, key := range q.keys {
+		if key.Hash == hash && key.RevokedAt == nil {
+			return key, nil
+		}
+	}
+
+	return key, ErrAPIKeyNotFound
+}
+
+// RevokeAPIKey - mocked revoke function to be used in tests
+func (q *MongoTestRepository) RevokeAPIKey(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key := range q.keys {
+		if q.keys[key].ID.Hex() == id && q.keys[key].RevokedAt == nil {
+			currentTime := time.Now()
+			q.keys[key].RevokedAt = &currentTime
+			return nil
+		}
+	}
+
+	return ErrAPIKeyNotFound
+}
+
+// InsertTenant - mocked insert function to be used in tests (keeps the tenant in memory)
+func (q *MongoTestRepository) InsertTenant(tenant Tenant) (Tenant, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, current := range q.tenants {
+		if current.RegisteredNumber == tenant.RegisteredNumber {
+			return tenant, ErrTenantExists
+		}
+	}
+
+	currentTime := time.Now()
+	tenant.ID = primitive.NewObjectID()
+	tenant.CreatedAt = &currentTime
+	tenant.UpdatedAt = &currentTime
+
+	q.tenants = append(q.tenants, tenant)
+
+	return tenant, nil
+}
+
+// FindTenants - mocked find function to be used in tests
+func (q *MongoTestRepository) FindTenants() (tenants []Tenant, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	tenants = make([]Tenant, 0, len(q.tenants))
+	tenants = append(tenants, q.tenants...)
+
+	return tenants, nil
+}
+
+// FindTenantByID - mocked find function to be used in tests
+func (q *MongoTestRepository) FindTenantByID(id string) (tenant Tenant, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, tenant := range q.tenants {
+		if tenant.ID.Hex() == id {
+			return tenant, nil
+		}
+	}
+
+	return tenant, ErrTenantNotFound
+}
+
+// FindTenantByRegisteredNumber - mocked find function to be used in tests
+func (q *MongoTestRepository) FindTenantByRegisteredNumber(registeredNumber string) (tenant Tenant, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, tenant := range q.tenants {
+		if tenant.RegisteredNumber == registeredNumber {
+			return tenant, nil
+		}
+	}
+
+	return tenant, ErrTenantNotFound
+}
+
+// UpdateTenant - mocked update function to be used in tests (registered number and creation date are kept)
+func (q *MongoTestRepository) UpdateTenant(tenant Tenant) (Tenant, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, current := range q.tenants {
+		if current.ID == tenant.ID {
+			currentTime := time.Now()
+			tenant.RegisteredNumber = current.RegisteredNumber
+			tenant.CreatedAt = current.CreatedAt
+			tenant.UpdatedAt = &currentTime
+
+			q.tenants[key] = tenant
+			return tenant, nil
+		}
+	}
+
+	return tenant, ErrTenantNotFound
+}
+
+// DeleteTenant - mocked delete function to be used in tests
+func (q *MongoTestRepository) DeleteTenant(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for key, tenant := range q.tenants {
+		if tenant.ID.Hex() == id {
+			q.tenants = append(q.tenants[:key], q.tenants[key+1:]...)
+			return nil
+		}
 	}
 
-	return quotes, err
+	return ErrTenantNotFound
 }
@@ -0,0 +1,100 @@
+package fakefr
+
+import "time"
+
+// Request - subset of freterapido simulate request read by the fake server
+type Request struct {
+	Shipper struct {
+		RegisteredNumber string `json:"registered_number"`
+		Token            string `json:"token"`
+		PlatformCode     string `json:"platform_code"`
+	} `json:"shipper"`
+	Recipient struct {
+		Type    int    `json:"type"`
+		Country string `json:"country"`
+		Zipcode int    `json:"zipcode"`
+	} `json:"recipient"`
+	Dispatchers []Dispatcher `json:"dispatchers"`
+}
+
+// Dispatcher - origin of the volumes
+type Dispatcher struct {
+	RegisteredNumber string   `json:"registered_number"`
+	Zipcode          int      `json:"zipcode"`
+	Volumes          []Volume `json:"volumes"`
+}
+
+// Volume - package to be shipped
+type Volume struct {
+	Category      string  `json:"category"`
+	Amount        int     `json:"amount"`
+	UnitaryWeight int     `json:"unitary_weight"`
+	Price         int     `json:"price"`
+	UnitaryPrice  int     `json:"unitary_price"`
+	Sku           string  `json:"sku"`
+	Height        float64 `json:"height"`
+	Width         float64 `json:"width"`
+	Length        float64 `json:"length"`
+}
+
+// Response - freterapido simulate response
+type Response struct {
+	Dispatchers []DispatcherResponse `json:"dispatchers"`
+}
+
+// DispatcherResponse - offers for a single origin
+type DispatcherResponse struct {
+	ID                         string  `json:"id"`
+	RequestID                  string  `json:"request_id"`
+	RegisteredNumberShipper    string  `json:"registered_number_shipper"`
+	RegisteredNumberDispatcher string  `json:"registered_number_dispatcher"`
+	ZipcodeOrigin              int     `json:"zipcode_origin"`
+	Offers                     []Offer `json:"offers"`
+}
+
+// Offer - a carrier's offer
+type Offer struct {
+	Offer                       int          `json:"offer"`
+	TableReference              string       `json:"table_reference"`
+	SimulationType              int          `json:"simulation_type"`
+	Carrier                     Carrier      `json:"carrier"`
+	Service                     string       `json:"service"`
+	DeliveryTime                DeliveryTime `json:"delivery_time"`
+	Expiration                  time.Time    `json:"expiration"`
+	CostPrice                   float64      `json:"cost_price"`
+	FinalPrice                  float64      `json:"final_price"`
+	Weights                     Weights      `json:"weights"`
+	OriginalDeliveryTime        DeliveryTime `json:"original_delivery_time"`
+	HomeDelivery                bool         `json:"home_delivery"`
+	CarrierOriginalDeliveryTime DeliveryTime `json:"carrier_original_delivery_time"`
+	Modal                       string       `json:"modal"`
+}
+
+// Carrier - carrier's info
+type Carrier struct {
+	Name             string `json:"name"`
+	RegisteredNumber string `json:"registered_number"`
+	StateInscription string `json:"state_inscription"`
+	Logo             string `json:"logo"`
+	Reference        int    `json:"reference"`
+	CompanyName      string `json:"company_name"`
+}
+
+// DeliveryTime - deadline in days and estimated date
+type DeliveryTime struct {
+	Days          int    `json:"days"`
+	EstimatedDate string `json:"estimated_date"`
+}
+
+// Weights - real, cubed and used weights
+type Weights struct {
+	Real  int     `json:"real"`
+	Cubed float64 `json:"cubed"`
+	Used  float64 `json:"used"`
+}
+
+// errorResponse - body sent back on client/server errors
+type errorResponse struct {
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+}
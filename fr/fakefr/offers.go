@@ -0,0 +1,108 @@
+package fakefr
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// cubingFactor - kg per m³ used to calculate cubed weight
+const cubingFactor = 300
+
+// rate - a carrier's (fake) price table
+type rate struct {
+	Carrier    Carrier
+	Service    string
+	Modal      string
+	Days       int
+	Base       float64 // fixed price
+	PerKg      float64 // price per used kg
+	AdValorem  float64 // percentage of declared value
+	CostMargin float64 // cost price = final price * (1 - margin)
+}
+
+// rates - carriers answered by the fake server (names as returned by freterapido sandbox)
+var rates = []rate{
+	{Carrier: Carrier{Name: "BOX DELIVERY", RegisteredNumber: "17108298000114", CompanyName: "BOX DELIVERY LTDA", Reference: 374}, Service: "Normal", Modal: "Rodoviário", Days: 1, Base: 0, PerKg: 0, AdValorem: 0},
+	{Carrier: Carrier{Name: "AZUL CARGO", RegisteredNumber: "09296295000160", CompanyName: "AZUL LINHAS AEREAS BRASILEIRAS S.A.", Reference: 281}, Service: "Standard", Modal: "Aéreo", Days: 2, Base: 21.5, PerKg: 1.2, AdValorem: 0.01, CostMargin: 0.1},
+	{Carrier: Carrier{Name: "PRESSA FR (TESTE)", RegisteredNumber: "31497049000103", CompanyName: "PRESSA FR", Reference: 346}, Service: "Normal", Modal: "Rodoviário", Days: 4, Base: 38.95, PerKg: 0.9, AdValorem: 0.005, CostMargin: 0.15},
+	{Carrier: Carrier{Name: "FR EXPRESS (TESTE)", RegisteredNumber: "31497049000103", CompanyName: "FR EXPRESS", Reference: 347}, Service: "Expresso", Modal: "Rodoviário", Days: 3, Base: 54.95, PerKg: 1.1, AdValorem: 0.005, CostMargin: 0.15},
+	{Carrier: Carrier{Name: "BTU BRASPRESS", RegisteredNumber: "48740351000165", CompanyName: "BRASPRESS TRANSPORTES URGENTES LTDA", Reference: 2}, Service: "Normal", Modal: "Rodoviário", Days: 5, Base: 63.35, PerKg: 1.5, AdValorem: 0.01, CostMargin: 0.12},
+	{Carrier: Carrier{Name: "CORREIOS", RegisteredNumber: "34028316000103", CompanyName: "EMPRESA BRASILEIRA DE CORREIOS E TELEGRAFOS", Reference: 281}, Service: "PAC", Modal: "Rodoviário", Days: 5, Base: 73.71, PerKg: 1.6, AdValorem: 0.01, CostMargin: 0.05},
+	{Carrier: Carrier{Name: "CORREIOS - SEDEX", RegisteredNumber: "34028316000103", CompanyName: "EMPRESA BRASILEIRA DE CORREIOS E TELEGRAFOS", Reference: 282}, Service: "SEDEX", Modal: "Rodoviário", Days: 2, Base: 91.03, PerKg: 2.4, AdValorem: 0.01, CostMargin: 0.05},
+	{Carrier: Carrier{Name: "BRASPRESS", RegisteredNumber: "48740351000165", CompanyName: "BRASPRESS TRANSPORTES URGENTES LTDA", Reference: 3}, Service: "Normal", Modal: "Rodoviário", Days: 4, Base: 103.58, PerKg: 1.7, AdValorem: 0.01, CostMargin: 0.12},
+}
+
+// buildResponse - creates offers for every dispatcher in the request
+func buildResponse(req Request, now time.Time) (res Response) {
+	requestID := fmt.Sprintf("%x", now.UnixNano())
+
+	for key, dispatcher := range req.Dispatchers {
+		// weights and declared value
+		var realWeight int
+		var cubicMeters, declared float64
+		for _, volume := range dispatcher.Volumes {
+			realWeight += volume.UnitaryWeight * volume.Amount
+			cubicMeters += volume.Height * volume.Width * volume.Length * float64(volume.Amount)
+			declared += float64(volume.Price)
+		}
+		cubed := round(cubicMeters * cubingFactor)
+		used := math.Max(float64(realWeight), cubed)
+
+		// farther destinations take longer
+		extraDays := distance(dispatcher.Zipcode, req.Recipient.Zipcode)
+
+		dispatcherRes := DispatcherResponse{
+			ID:                         fmt.Sprintf("%s-%d", requestID, key),
+			RequestID:                  requestID,
+			RegisteredNumberShipper:    req.Shipper.RegisteredNumber,
+			RegisteredNumberDispatcher: dispatcher.RegisteredNumber,
+			ZipcodeOrigin:              dispatcher.Zipcode,
+			Offers:                     []Offer{},
+		}
+
+		for offerKey, rate := range rates {
+			finalPrice := round(rate.Base + rate.PerKg*used + rate.AdValorem*declared)
+			days := rate.Days + extraDays
+			deliveryTime := DeliveryTime{
+				Days:          days,
+				EstimatedDate: now.AddDate(0, 0, days).Format("2006-01-02"),
+			}
+
+			dispatcherRes.Offers = append(dispatcherRes.Offers, Offer{
+				Offer:                       offerKey + 1,
+				TableReference:              fmt.Sprintf("%d-%s", rate.Carrier.Reference, rate.Service),
+				SimulationType:              0,
+				Carrier:                     rate.Carrier,
+				Service:                     rate.Service,
+				DeliveryTime:                deliveryTime,
+				Expiration:                  now.AddDate(0, 0, 30).UTC().Truncate(time.Second),
+				CostPrice:                   round(finalPrice * (1 - rate.CostMargin)),
+				FinalPrice:                  finalPrice,
+				Weights:                     Weights{Real: realWeight, Cubed: cubed, Used: used},
+				OriginalDeliveryTime:        deliveryTime,
+				HomeDelivery:                true,
+				CarrierOriginalDeliveryTime: deliveryTime,
+				Modal:                       rate.Modal,
+			})
+		}
+
+		res.Dispatchers = append(res.Dispatchers, dispatcherRes)
+	}
+
+	return res
+}
+
+// distance - rough number of extra delivery days between two zipcodes (by region digit)
+func distance(origin, destination int) int {
+	region := func(zipcode int) int {
+		return zipcode / 10000000
+	}
+
+	diff := region(origin) - region(destination)
+	if diff < 0 {
+		diff = -diff
+	}
+
+	return diff / 2
+}
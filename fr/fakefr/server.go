@@ -0,0 +1,216 @@
+// Package fakefr - fake freterapido simulate api, to be used in offline development and tests
+package fakefr
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// SimulatePath - path where freterapido simulate module answers
+const SimulatePath = "/api/v3/quote/simulate"
+
+// ScenarioHeader - header that overrides the server's scenario for a single request
+const ScenarioHeader = "X-Fake-Scenario"
+
+// Scenario - how the fake server answers a request
+type Scenario string
+
+const (
+	ScenarioOK               Scenario = "ok"                // realistic offers
+	ScenarioSlow             Scenario = "slow"              // realistic offers, after Options.Delay
+	ScenarioBadRequest       Scenario = "bad_request"       // 400 with an error body
+	ScenarioUnauthorized     Scenario = "unauthorized"      // 401 with an error body
+	ScenarioServerError      Scenario = "server_error"      // 500 with an error body
+	ScenarioMalformed        Scenario = "malformed"         // 200 with an invalid json body
+	ScenarioEmptyDispatchers Scenario = "empty_dispatchers" // 200 without dispatchers
+	ScenarioZeroPrice        Scenario = "zero_price"        // 200 with offers that cost nothing
+)
+
+const defaultDelay = 2 * time.Second
+
+// Options - fake server's behaviour
+type Options struct {
+	Scenario Scenario      // default: ScenarioOK
+	Delay    time.Duration // used by ScenarioSlow (default: 2s)
+	Token    string        // when set, requests with another token receive 401
+}
+
+// Handler - http handler that mimics freterapido simulate module
+type Handler struct {
+	mu       sync.Mutex
+	opts     Options
+	requests []Request
+}
+
+// NewHandler - creates a fake simulate handler
+func NewHandler(opts Options) *Handler {
+	if opts.Scenario == "" {
+		opts.Scenario = ScenarioOK
+	}
+
+	if opts.Delay <= 0 {
+		opts.Delay = defaultDelay
+	}
+
+	return &Handler{opts: opts}
+}
+
+// SetScenario - changes how the following requests are answered
+func (h *Handler) SetScenario(scenario Scenario) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.opts.Scenario = scenario
+}
+
+// Requests - returns every (decoded) request received so far
+func (h *Handler) Requests() []Request {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]Request(nil), h.requests...)
+}
+
+// ServeHTTP - answers a simulate request according to the current scenario
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: true, Message: "method not allowed"})
+		return
+	}
+
+	h.mu.Lock()
+	opts := h.opts
+	h.mu.Unlock()
+
+	if scenario := r.Header.Get(ScenarioHeader); scenario != "" {
+		opts.Scenario = Scenario(scenario)
+	}
+
+	// decode request
+	var req Request
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: true, Message: fmt.Sprintf("invalid json: %v", err)})
+		return
+	}
+
+	h.mu.Lock()
+	h.requests = append(h.requests, req)
+	h.mu.Unlock()
+
+	switch opts.Scenario {
+	case ScenarioSlow:
+		select {
+		case <-time.After(opts.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	case ScenarioBadRequest:
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: true, Message: "recipient.zipcode: invalid zipcode"})
+		return
+	case ScenarioUnauthorized:
+		writeJSON(w, http.StatusUnauthorized, errorResponse{Error: true, Message: "shipper.token: invalid token"})
+		return
+	case ScenarioServerError:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: true, Message: "internal server error"})
+		return
+	case ScenarioMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"dispatchers": [{"offers": [`))
+		return
+	case ScenarioEmptyDispatchers:
+		writeJSON(w, http.StatusOK, Response{Dispatchers: []DispatcherResponse{}})
+		return
+	}
+
+	// validate request like freterapido does
+	if msg := validate(req, opts.Token); msg != "" {
+		status := http.StatusBadRequest
+		if msg == "shipper.token: invalid token" {
+			status = http.StatusUnauthorized
+		}
+
+		writeJSON(w, status, errorResponse{Error: true, Message: msg})
+		return
+	}
+
+	res := buildResponse(req, time.Now())
+	if opts.Scenario == ScenarioZeroPrice {
+		for d := range res.Dispatchers {
+			for o := range res.Dispatchers[d].Offers {
+				res.Dispatchers[d].Offers[o].CostPrice = 0
+				res.Dispatchers[d].Offers[o].FinalPrice = 0
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// Server - fake simulate api listening on a local address (httptest)
+type Server struct {
+	*httptest.Server
+	Handler *Handler
+}
+
+// NewServer - starts a fake simulate api (callers must Close it)
+func NewServer(opts Options) *Server {
+	handler := NewHandler(opts)
+
+	mux := http.NewServeMux()
+	mux.Handle(SimulatePath, handler)
+
+	return &Server{
+		Server:  httptest.NewServer(mux),
+		Handler: handler,
+	}
+}
+
+// SimulateURL - full url of the simulate module
+func (s *Server) SimulateURL() string {
+	return s.URL + SimulatePath
+}
+
+// validate - returns the first problem found in a request (empty when valid)
+func validate(req Request, token string) string {
+	switch {
+	case req.Shipper.Token == "" || (token != "" && req.Shipper.Token != token):
+		return "shipper.token: invalid token"
+	case req.Shipper.RegisteredNumber == "":
+		return "shipper.registered_number: required"
+	case req.Recipient.Zipcode == 0:
+		return "recipient.zipcode: invalid zipcode"
+	case len(req.Dispatchers) == 0:
+		return "dispatchers: required"
+	}
+
+	for key, dispatcher := range req.Dispatchers {
+		if dispatcher.Zipcode == 0 {
+			return fmt.Sprintf("dispatchers[%d].zipcode: invalid zipcode", key)
+		}
+
+		if len(dispatcher.Volumes) == 0 {
+			return fmt.Sprintf("dispatchers[%d].volumes: required", key)
+		}
+	}
+
+	return ""
+}
+
+// writeJSON - writes a json response
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// round - rounds a price to cents
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}